@@ -24,6 +24,15 @@ func NewRestClient(serverURL, authToken string, headers map[string]string) *Rest
 	}
 }
 
+// StatusError is the answer of the server to a failed request.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error with status code %d", e.StatusCode)
+}
+
 func check(response *http.Response) ([]byte, error) {
 	if response != nil && (response.StatusCode == http.StatusOK || response.StatusCode == http.StatusCreated) {
 		body, err := io.ReadAll(response.Body)
@@ -33,7 +42,7 @@ func check(response *http.Response) ([]byte, error) {
 		return body, nil
 	} else if response != nil {
 		fmt.Printf("Error %d: %s\n", response.StatusCode, response.Request.URL)
-		return nil, fmt.Errorf("error with status code %d", response.StatusCode)
+		return nil, &StatusError{StatusCode: response.StatusCode}
 	} else {
 		fmt.Println("Error - No response.")
 		return nil, fmt.Errorf("no response from server")
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+const botIDKey = "bot_id"
+
+// ensureBot returns the user ID of the plugin's bot, creating the bot on
+// first activation. The ID is kept in the KV store.
+func (p *AnchorPlugin) ensureBot() (string, error) {
+	data, appErr := p.API.KVGet(botIDKey)
+	if appErr != nil {
+		return "", appErr
+	}
+	if data != nil {
+		if _, appErr := p.API.GetUser(string(data)); appErr == nil {
+			return string(data), nil
+		}
+	}
+
+	var botID string
+	user, appErr := p.API.GetUserByUsername(config.BotUsername)
+	switch {
+	case appErr == nil && !user.IsBot:
+		return "", errors.New("the bot user name " + config.BotUsername + " is taken by a user")
+	case appErr == nil:
+		botID = user.Id
+	default:
+		bot, appErr := p.API.CreateBot(&model.Bot{
+			Username:    config.BotUsername,
+			DisplayName: config.BotDisplayName,
+			Description: config.BotDescription,
+		})
+		if appErr != nil {
+			return "", appErr
+		}
+		botID = bot.UserId
+	}
+
+	if appErr := p.API.KVSet(botIDKey, []byte(botID)); appErr != nil {
+		return "", appErr
+	}
+	return botID, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/fakeapi"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"testing"
+)
+
+func TestEnsureBotIsIdempotent(t *testing.T) {
+	p, api := newTestPlugin(model.SystemUserRoleId)
+
+	botID, err := p.ensureBot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if botID != p.botID {
+		t.Errorf("bot %s created again, had %s", botID, p.botID)
+	}
+
+	// The bot is found by name when its ID was lost
+	if appErr := api.KVDelete(botIDKey); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if botID, err := p.ensureBot(); err != nil || botID != p.botID {
+		t.Errorf("ensureBot() = %s, %v, want %s", botID, err, p.botID)
+	}
+	if calls := api.Calls("CreateBot"); calls != 1 {
+		t.Errorf("CreateBot called %d times, want 1", calls)
+	}
+}
+
+func TestEnsureBotRejectsTakenName(t *testing.T) {
+	api := fakeapi.New()
+	api.AddUser(config.BotUsername, model.SystemUserRoleId)
+	p := &AnchorPlugin{}
+	p.SetAPI(api)
+
+	if _, err := p.ensureBot(); err == nil {
+		t.Error("a user's name was taken for the bot")
+	}
+}
@@ -0,0 +1,94 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"time"
+)
+
+// ChannelActivity sums up the posts of members in a channel of the structure
+// over a period. LastActivity is the time of the last post of a member, zero if
+// no member ever posted there.
+type ChannelActivity struct {
+	Channel      string
+	Posts        int
+	Posters      int
+	LastActivity time.Time
+}
+
+// CategoryActivity is the activity of the channels of a category.
+type CategoryActivity struct {
+	Category string
+	Channels []ChannelActivity
+}
+
+// ChannelActivity reports the activity in the channels of the structure over
+// the last days, grouped by category. Messages of the system, such as joins,
+// are not counted. Channels which could not be found or read are reported as a
+// partial failure.
+func (t *Team) ChannelActivity(days int) ([]CategoryActivity, error) {
+	var activities []CategoryActivity
+	failed := failures{op: "report channel activity"}
+	since := now().AddDate(0, 0, -days)
+
+	for _, category := range config.Structure {
+		activity := CategoryActivity{Category: category.Name}
+
+		for _, entry := range category.Channels {
+			channel, err := t.channels.Resolve(entry)
+			if err != nil {
+				failed.add(entry.String(), err)
+				continue
+			}
+
+			channelActivity, err := t.channelActivity(channel, since)
+			if err != nil {
+				failed.add(entry.String(), err)
+				continue
+			}
+			activity.Channels = append(activity.Channels, *channelActivity)
+		}
+
+		activities = append(activities, activity)
+	}
+
+	return activities, failed.err()
+}
+
+// private
+
+// channelActivity pages through the posts of the channel, newest first, until
+// they are older than the period and the last post of a member was found.
+func (t *Team) channelActivity(channel *model.Channel, since time.Time) (*ChannelActivity, error) {
+	activity := &ChannelActivity{Channel: channel.DisplayName}
+	posters := make(map[string]bool)
+
+	for page := 0; ; page++ {
+		list, appErr := t.c.API.GetPostsForChannel(channel.Id, page, postsPerPage)
+		if appErr != nil {
+			return nil, apiError("list posts of channel", channel.Name, appErr)
+		}
+		if len(list.Order) == 0 {
+			break
+		}
+
+		for _, postID := range list.Order {
+			post := list.Posts[postID]
+			if post.IsSystemMessage() || post.DeleteAt != 0 {
+				continue
+			}
+			if activity.LastActivity.IsZero() {
+				activity.LastActivity = time.UnixMilli(post.CreateAt)
+			}
+			if post.CreateAt < since.UnixMilli() {
+				activity.Posters = len(posters)
+				return activity, nil
+			}
+			activity.Posts++
+			posters[post.UserId] = true
+		}
+	}
+	activity.Posters = len(posters)
+
+	return activity, nil
+}
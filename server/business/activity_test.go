@@ -0,0 +1,70 @@
+package business
+
+import (
+	"github.com/mattermost/mattermost-server/v6/model"
+	"testing"
+	"time"
+)
+
+func TestChannelActivity(t *testing.T) {
+	f := newFixture(t)
+	skipper := f.addUser("skipper")
+	carPool := f.channels["car-pool"].Id
+
+	// An old post, edited within the period
+	old := f.api.AddPost(carPool, skipper.Id, "", "Room for a boat on the trailer")
+	old.CreateAt = time.Now().AddDate(0, 0, -20).UnixMilli()
+	old.UpdateAt = model.GetMillis()
+	f.api.AddPost(carPool, skipper.Id, "", "Anyone driving to Kaag?")
+	f.api.AddPost(carPool, f.admin.Id, "", "I am")
+	f.api.AddPost(carPool, skipper.Id, "", "Great")
+	f.api.AddPost(carPool, skipper.Id, model.PostTypeJoinChannel, "skipper joined the channel.")
+
+	// More posts than a page holds
+	offTopic := f.channels["off-topic"].Id
+	for i := 0; i < postsPerPage+50; i++ {
+		f.api.AddPost(offTopic, skipper.Id, "", "Fair winds")
+	}
+	f.api.AddPost(f.channels["buzz"].Id, skipper.Id, model.PostTypeJoinChannel, "skipper joined the channel.")
+
+	activities, err := WrapTeam(f.c, f.team).ChannelActivity(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found *ChannelActivity
+	for _, activity := range activities {
+		for i, channel := range activity.Channels {
+			if channel.Channel == "Car Pool" {
+				found = &activity.Channels[i]
+			}
+			if channel.Channel == "Buzz" && (channel.Posts != 0 || !channel.LastActivity.IsZero()) {
+				t.Errorf("buzz = %+v, want no activity of members", channel)
+			}
+			if channel.Channel == "Off-Topic" && channel.Posts != postsPerPage+50 {
+				t.Errorf("off-topic = %+v, want %d posts", channel, postsPerPage+50)
+			}
+		}
+	}
+	if found == nil || found.Posts != 3 || found.Posters != 2 || time.Since(found.LastActivity) > time.Minute {
+		t.Errorf("car pool = %+v, want 3 posts by 2 members just now", found)
+	}
+
+	now = func() time.Time { return time.Now().AddDate(0, 0, 10) }
+	defer func() { now = time.Now }()
+
+	activities, err = WrapTeam(f.c, f.team).ChannelActivity(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, activity := range activities {
+		for _, channel := range activity.Channels {
+			if channel.Posts != 0 {
+				t.Errorf("%s has %d posts, want none in the period", channel.Channel, channel.Posts)
+			}
+			if channel.Channel == "Car Pool" && time.Since(channel.LastActivity) > time.Minute {
+				t.Errorf("car pool was last active %v, want the last post before the period", channel.LastActivity)
+			}
+		}
+	}
+}
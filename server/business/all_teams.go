@@ -0,0 +1,94 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/models"
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+// TeamOnboarding is the outcome of onboarding a user in one of their teams.
+type TeamOnboarding struct {
+	Team   *model.Team
+	Result *OnboardingResult
+	Err    error
+}
+
+// TeamStructureReport is the outcome of checking a user in one of their teams.
+type TeamStructureReport struct {
+	Team   *model.Team
+	Report *StructureReport
+	Err    error
+}
+
+// TeamsOfUser lists the teams the user is a member of.
+func TeamsOfUser(c *models.Context, user *model.User) ([]*model.Team, error) {
+	teams, appErr := c.API.GetTeamsForUser(user.Id)
+	if appErr != nil {
+		return nil, apiError("list teams of user", user.Username, appErr)
+	}
+	return teams, nil
+}
+
+// OnboardAllTeams onboards the user in every team they are a member of, with
+// the channels of the structure found in each team. Teams in which the
+// onboarding failed are reported as a partial failure.
+func OnboardAllTeams(c *models.Context, user *model.User) ([]TeamOnboarding, error) {
+	var outcomes []TeamOnboarding
+	failed := failures{op: "onboard " + user.Username + " in all teams"}
+
+	teams, err := TeamsOfUser(c, user)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, team := range teams {
+		outcome := TeamOnboarding{Team: team}
+
+		s, err := NewSideBar(WrapUser(teamContext(c, team), user))
+		if err == nil {
+			outcome.Result, err = s.CheckAndJoinDefaultChannelStructure()
+		}
+		if err != nil {
+			outcome.Err = err
+			failed.add(team.Name, NewError(KindOf(err), "onboard in team", team.Name, err))
+		}
+		outcomes = append(outcomes, outcome)
+	}
+
+	return outcomes, failed.err()
+}
+
+// CheckAllTeams checks the user in every team they are a member of.
+func CheckAllTeams(c *models.Context, user *model.User) ([]TeamStructureReport, error) {
+	var reports []TeamStructureReport
+	failed := failures{op: "check " + user.Username + " in all teams"}
+
+	teams, err := TeamsOfUser(c, user)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, team := range teams {
+		report := TeamStructureReport{Team: team}
+
+		s, err := NewSideBar(WrapUser(teamContext(c, team), user))
+		if err == nil {
+			report.Report, err = s.CheckChannelStructure()
+		}
+		if err != nil {
+			report.Err = err
+			failed.add(team.Name, NewError(KindOf(err), "check in team", team.Name, err))
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, failed.err()
+}
+
+// private
+
+// teamContext returns a copy of the context acting in another team.
+func teamContext(c *models.Context, team *model.Team) *models.Context {
+	teamContext := *c
+	teamContext.Team = team
+	return &teamContext
+}
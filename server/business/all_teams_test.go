@@ -0,0 +1,63 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"testing"
+)
+
+func TestOnboardAllTeams(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+
+	// A second team holding the channels of the structure as well
+	ops := f.api.AddTeam("ops")
+	opsChannels := make(map[string]*model.Channel)
+	for _, category := range config.Structure {
+		for _, entry := range category.Channels {
+			if !entry.Private {
+				opsChannels[entry.Name] = f.api.AddChannel(ops.Id, entry.Name, entry.DisplayName, model.ChannelTypeOpen)
+			}
+		}
+	}
+	f.api.AddTeamMember(ops.Id, user.Id)
+
+	outcomes, err := OnboardAllTeams(f.c, user)
+	if err != nil {
+		t.Fatalf("OnboardAllTeams: %v", err)
+	}
+	if len(outcomes) != 2 || outcomes[0].Team.Id != f.team.Id || outcomes[1].Team.Id != ops.Id {
+		t.Fatalf("outcomes = %+v, want one per team", outcomes)
+	}
+	for _, outcome := range outcomes {
+		if outcome.Result == nil || outcome.Err != nil {
+			t.Errorf("team %s: result = %+v, err = %v", outcome.Team.Name, outcome.Result, outcome.Err)
+		}
+	}
+	if !f.api.IsMember(opsChannels["town-square"].Id, user.Id) {
+		t.Errorf("user did not join town-square of the second team")
+	}
+	if len(f.api.Categories(user.Id, ops.Id)) == 0 {
+		t.Errorf("no sidebar categories in the second team")
+	}
+
+	reports, err := CheckAllTeams(f.c, user)
+	if err != nil {
+		t.Fatalf("CheckAllTeams: %v", err)
+	}
+	for _, report := range reports {
+		if len(report.Report.MissingChannels) > 0 || len(report.Report.MissingCategories) > 0 {
+			t.Errorf("team %s: report = %+v, want compliant", report.Team.Name, report.Report)
+		}
+	}
+}
+
+func TestOnboardAllTeamsWithoutTeams(t *testing.T) {
+	f := newFixture(t)
+	user := f.api.AddUser("drifter", model.SystemUserRoleId)
+
+	outcomes, err := OnboardAllTeams(f.c, user)
+	if err != nil || len(outcomes) != 0 {
+		t.Errorf("outcomes = %+v, err = %v, want none", outcomes, err)
+	}
+}
@@ -0,0 +1,128 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/utils"
+	"strings"
+)
+
+// audienceProfile holds what audiences are matched against. Groups and team
+// roles are only loaded when an audience asks for them, and then once per user.
+type audienceProfile struct {
+	groups    []string
+	teamRoles []string
+
+	groupsLoaded bool
+	rolesLoaded  bool
+}
+
+// members
+
+// Structure returns the part of the structure meant for the user now, see
+// config.StructureFor. Unless ended channels are archived, the ended channels
+// the user is still a member of follow in the archive category.
+func (u *User) Structure() ([]config.Category, error) {
+	if u.structure == nil {
+		structure, err := config.StructureFor(now(), u.BelongsTo)
+		if err != nil {
+			return nil, err
+		}
+
+		if !config.ArchiveEndedChannels {
+			ended, err := u.endedChannels()
+			if err != nil {
+				return nil, err
+			}
+			if len(ended) > 0 {
+				structure = append(structure, config.Category{Name: config.ArchiveCategory, Policy: config.ArchivePolicy, Channels: ended})
+			}
+		}
+
+		u.structure = structure
+	}
+	return u.structure, nil
+}
+
+// BelongsTo tells whether the user belongs to the audience.
+func (u *User) BelongsTo(audience config.Audience) (bool, error) {
+	if audience.Everyone() {
+		return true, nil
+	}
+
+	for prop, value := range audience.Props {
+		if u.Props[prop] == value {
+			return true, nil
+		}
+	}
+
+	if len(audience.TeamRoles) > 0 {
+		teamRoles, err := u.teamRoles()
+		if err != nil {
+			return false, err
+		}
+		for _, role := range audience.TeamRoles {
+			if utils.Contains(teamRoles, role) {
+				return true, nil
+			}
+		}
+	}
+
+	if len(audience.Groups) > 0 {
+		groups, err := u.groups()
+		if err != nil {
+			return false, err
+		}
+		for _, group := range audience.Groups {
+			if utils.Contains(groups, group) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// private
+
+// groups returns the names and display names of the groups of the user.
+func (u *User) groups() ([]string, error) {
+	if !u.audience.groupsLoaded {
+		groups, appErr := u.c.API.GetGroupsForUser(u.Id)
+		if appErr != nil {
+			return nil, apiError("get groups of user", u.Username, appErr)
+		}
+		for _, group := range groups {
+			if group.Name != nil {
+				u.audience.groups = append(u.audience.groups, *group.Name)
+			}
+			u.audience.groups = append(u.audience.groups, group.DisplayName)
+		}
+		u.audience.groupsLoaded = true
+	}
+	return u.audience.groups, nil
+}
+
+// teamRoles returns the roles of the user in the team, including those
+// granted by the permission scheme.
+func (u *User) teamRoles() ([]string, error) {
+	if !u.audience.rolesLoaded {
+		member, appErr := u.c.API.GetTeamMember(u.c.Team.Id, u.Id)
+		if appErr != nil {
+			return nil, apiError("get team roles of user", u.Username, appErr)
+		}
+
+		roles := strings.Fields(member.Roles)
+		if member.SchemeGuest {
+			roles = append(roles, "team_guest")
+		}
+		if member.SchemeUser {
+			roles = append(roles, "team_user")
+		}
+		if member.SchemeAdmin {
+			roles = append(roles, "team_admin")
+		}
+		u.audience.teamRoles = roles
+		u.audience.rolesLoaded = true
+	}
+	return u.audience.teamRoles, nil
+}
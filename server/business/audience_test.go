@@ -0,0 +1,75 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"reflect"
+	"testing"
+)
+
+func TestAudienceTargeting(t *testing.T) {
+	structure := config.Structure
+	defer func() { config.Structure = structure }()
+
+	config.Structure = []config.Category{
+		{Name: "Club Life", Channels: []config.Channel{
+			{DisplayName: "Town Square", Name: "town-square"},
+			{DisplayName: "Committee", Name: "committee", Private: true},
+			{DisplayName: "Board", Name: "board", Private: true, Audience: config.Audience{TeamRoles: []string{model.TeamAdminRoleId}}},
+		}},
+		{Name: "Racing", Audience: config.Audience{Props: map[string]string{"crew": "racing"}}, Channels: []config.Channel{
+			{DisplayName: "Kaag Cup", Name: "kaag-cup"},
+		}},
+		{Name: "Training", Channels: []config.Channel{
+			{DisplayName: "Sign Up", Name: "sign-up"},
+			{DisplayName: "Instructors", Name: "instructors", Private: true, Audience: config.Audience{Groups: []string{"instructors"}}},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		prepare  func(f *fixture, user *model.User)
+		channels []string
+	}{
+		{"everybody", func(f *fixture, user *model.User) {}, []string{"town-square", "sign-up"}},
+		{"racing crew by profile", func(f *fixture, user *model.User) {
+			user.Props = model.StringMap{"crew": "racing"}
+		}, []string{"town-square", "kaag-cup", "sign-up"}},
+		{"instructor by group", func(f *fixture, user *model.User) {
+			f.api.AddGroupMember("instructors", user.Id)
+		}, []string{"town-square", "sign-up", "instructors"}},
+		{"team admin by role", func(f *fixture, user *model.User) {
+			f.api.SetTeamRoles(f.team.Id, user.Id, model.TeamUserRoleId+" "+model.TeamAdminRoleId)
+		}, []string{"town-square", "board", "sign-up"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFixture(t)
+			user := f.addUser("skipper")
+			test.prepare(f, user)
+
+			f.onboard(t, user)
+
+			var joined []string
+			for _, category := range config.Structure {
+				for _, entry := range category.Channels {
+					if f.api.IsMember(f.channels[entry.Name].Id, user.Id) {
+						joined = append(joined, entry.Name)
+					}
+				}
+			}
+			if !reflect.DeepEqual(joined, test.channels) {
+				t.Errorf("channels = %v, want %v", joined, test.channels)
+			}
+
+			report, err := f.sideBar(t, user).CheckChannelStructure()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.Compliant() {
+				t.Errorf("report after onboarding = %+v, want compliant", report)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package business
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/glass.plugin-anchor/server/models"
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+	auditKey = "audit_trail"
+	// auditLimit is the number of entries kept, the oldest ones are dropped
+	auditLimit = 500
+	// auditAttempts limits the retries of concurrent updates of the trail
+	auditAttempts = 5
+)
+
+// AuditEntry records a change the plugin made on behalf of an admin or of a
+// hook. Details lists what was changed.
+type AuditEntry struct {
+	Time    int64    `json:"time"`
+	Actor   string   `json:"actor"`
+	Action  string   `json:"action"`
+	Subject string   `json:"subject"`
+	Details []string `json:"details,omitempty"`
+}
+
+var errAuditContended = errors.New("the audit trail kept changing")
+
+// AuditTrail returns the recorded entries, oldest first.
+func AuditTrail(c *models.Context) ([]AuditEntry, error) {
+	entries, _, err := loadAuditTrail(c)
+	return entries, err
+}
+
+// RecordAudit appends an entry to the audit trail, stamped with the current
+// time if it has none. The trail is changed with compare-and-set, as hooks and
+// commands record entries concurrently.
+func RecordAudit(c *models.Context, entry AuditEntry) error {
+	if entry.Time == 0 {
+		entry.Time = model.GetMillis()
+	}
+
+	for attempt := 0; attempt < auditAttempts; attempt++ {
+		entries, old, err := loadAuditTrail(c)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry)
+		if len(entries) > auditLimit {
+			entries = entries[len(entries)-auditLimit:]
+		}
+
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return NewError(KindInvalid, "save audit trail", "", err)
+		}
+
+		saved, appErr := c.API.KVSetWithOptions(auditKey, data, model.PluginKVSetOptions{Atomic: true, OldValue: old})
+		if appErr != nil {
+			return apiError("save audit trail", "", appErr)
+		}
+		if saved {
+			return nil
+		}
+	}
+	return NewError(KindAPI, "save audit trail", "", errAuditContended)
+}
+
+// private
+
+func loadAuditTrail(c *models.Context) ([]AuditEntry, []byte, error) {
+	var entries []AuditEntry
+
+	data, appErr := c.API.KVGet(auditKey)
+	if appErr != nil {
+		return nil, nil, apiError("load audit trail", "", appErr)
+	}
+	if data == nil {
+		return entries, nil, nil
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, nil, NewError(KindInvalid, "load audit trail", "", err)
+	}
+	return entries, data, nil
+}
@@ -0,0 +1,30 @@
+package business
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRecordAuditConcurrently(t *testing.T) {
+	f := newFixture(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := RecordAudit(f.c, AuditEntry{Actor: "admin", Action: "offboard", Subject: "skipper"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := AuditTrail(f.c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("%d entries recorded, want 4", len(entries))
+	}
+}
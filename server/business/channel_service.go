@@ -1,31 +1,234 @@
 package business
 
 import (
+	"errors"
+	"fmt"
+	"github.com/glass.plugin-anchor/server/config"
 	"github.com/glass.plugin-anchor/server/models"
 	"github.com/mattermost/mattermost-server/v6/model"
 	"strings"
 )
 
-func GetChannelByDisplayName(c *models.Context, displayName string) (*model.Channel, *model.AppError) {
-	channelName := strings.ToLower(strings.ReplaceAll(displayName, " ", "-"))
+var (
+	ErrChannelNotFound  = errors.New("channel not found")
+	ErrChannelAmbiguous = errors.New("channel is ambiguous")
 
-	channel, appErr := c.API.GetChannelByName(c.Team.Id, channelName, false)
+	errNoURLName = errors.New("no URL name configured")
+)
+
+// ChannelIndex caches the channels of one team by ID and name together with the
+// channel memberships of its users, and maps the channel entries of the
+// structure definition to those channels. An index is meant to live for the
+// duration of one operation and to be shared by the Team, User and SideBar
+// wrappers taking part in it.
+type ChannelIndex struct {
+	c      *models.Context
+	teamID string
+
+	byID         map[string]*model.Channel
+	byName       map[string]*model.Channel
+	public       []*model.Channel
+	publicLoaded bool
+
+	members map[string]*memberships // by user ID
+}
+
+type memberships struct {
+	channels []*model.Channel
+	ids      map[string]bool
+}
+
+// Constructors
+
+func NewChannelIndex(c *models.Context, teamID string) *ChannelIndex {
+	return &ChannelIndex{
+		c:       c,
+		teamID:  teamID,
+		byID:    make(map[string]*model.Channel),
+		byName:  make(map[string]*model.Channel),
+		members: make(map[string]*memberships),
+	}
+}
+
+// members
+
+// Resolve returns the team channel bound to the entry. The ID takes precedence
+// over the URL name; the display name is only used when neither is configured
+// and fails with ErrChannelAmbiguous if several channels share it.
+func (x *ChannelIndex) Resolve(entry config.Channel) (*model.Channel, error) {
+	switch {
+	case entry.ID != "":
+		return x.resolveID(entry)
+	case entry.Name != "":
+		return x.resolveName(entry)
+	default:
+		return x.resolveDisplayName(entry)
+	}
+}
+
+// Channel returns the channel with the given ID.
+func (x *ChannelIndex) Channel(channelID string) (*model.Channel, error) {
+	if channel, cached := x.byID[channelID]; cached {
+		return channel, nil
+	}
+
+	channel, appErr := x.c.API.GetChannel(channelID)
+	if appErr != nil {
+		return nil, apiError("get channel", channelID, appErr)
+	}
+	x.remember(channel)
+
+	return channel, nil
+}
+
+// MemberChannels returns the channels of the team the user is a member of.
+func (x *ChannelIndex) MemberChannels(userID string) ([]*model.Channel, error) {
+	m, err := x.memberships(userID)
+	if err != nil {
+		return nil, err
+	}
+	return m.channels, nil
+}
+
+// IsMember tells whether the user is a member of the channel.
+func (x *ChannelIndex) IsMember(userID string, channelID string) (bool, error) {
+	m, err := x.memberships(userID)
+	if err != nil {
+		return false, err
+	}
+	return m.ids[channelID], nil
+}
+
+// AddMember records a membership created during the operation.
+func (x *ChannelIndex) AddMember(userID string, channel *model.Channel) {
+	if m, loaded := x.members[userID]; loaded && !m.ids[channel.Id] {
+		m.channels = append(m.channels, channel)
+		m.ids[channel.Id] = true
+	}
+}
+
+// RemoveMember records a membership removed during the operation.
+func (x *ChannelIndex) RemoveMember(userID string, channelID string) {
+	m, loaded := x.members[userID]
+	if !loaded || !m.ids[channelID] {
+		return
+	}
 
+	var channels []*model.Channel
+	for _, channel := range m.channels {
+		if channel.Id != channelID {
+			channels = append(channels, channel)
+		}
+	}
+	m.channels = channels
+	delete(m.ids, channelID)
+}
+
+func (x *ChannelIndex) memberships(userID string) (*memberships, error) {
+	if m, loaded := x.members[userID]; loaded {
+		return m, nil
+	}
+
+	channels, appErr := x.c.API.GetChannelsForTeamForUser(x.teamID, userID, false)
+	if appErr != nil {
+		return nil, apiError("get channels of user", userID, appErr)
+	}
+
+	m := &memberships{channels: channels, ids: make(map[string]bool, len(channels))}
+	for _, channel := range channels {
+		x.remember(channel)
+		m.ids[channel.Id] = true
+	}
+	x.members[userID] = m
+
+	return m, nil
+}
+
+func (x *ChannelIndex) resolveID(entry config.Channel) (*model.Channel, error) {
+	channel, err := x.Channel(entry.ID)
+	if err != nil {
+		return nil, notFoundChannel(entry, fmt.Sprintf("id %s", entry.ID))
+	}
+
+	if channel.TeamId != x.teamID {
+		return nil, notFoundChannel(entry, fmt.Sprintf("id %s belongs to another team", entry.ID))
+	}
+	return channel, nil
+}
+
+func (x *ChannelIndex) resolveName(entry config.Channel) (*model.Channel, error) {
+	if channel, cached := x.byName[entry.Name]; cached {
+		return channel, nil
+	}
+
+	channel, appErr := x.c.API.GetChannelByName(x.teamID, entry.Name, false)
 	if appErr != nil {
-		return nil, appErr
+		return nil, notFoundChannel(entry, fmt.Sprintf("name %s", entry.Name))
 	}
+	x.remember(channel)
 
 	return channel, nil
 }
 
-func createChannelName(displayName string) string {
-	return strings.ReplaceAll(strings.ToLower(displayName), " ", "-")
+func (x *ChannelIndex) resolveDisplayName(entry config.Channel) (*model.Channel, error) {
+	channels, err := x.publicChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*model.Channel
+	for _, channel := range channels {
+		if channel.DisplayName == entry.DisplayName {
+			matches = append(matches, channel)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, notFoundChannel(entry, "no public channel with this display name, configure its name or id")
+	case 1:
+		return matches[0], nil
+	default:
+		var names []string
+		for _, channel := range matches {
+			names = append(names, channel.Name)
+		}
+		return nil, NewError(KindInvalid, "resolve channel", entry.String(),
+			fmt.Errorf("%w (matches %s, configure its name or id)", ErrChannelAmbiguous, strings.Join(names, ", ")))
+	}
 }
 
-//func getChannelNameByID(c *models.Context, channelID string) (string, error) {
-//	channel, err := c.API.GetChannel(channelID)
-//	if err != nil {
-//		return "", fmt.Errorf("could not retrieve channel with ID %s: %w", channelID, err)
-//	}
-//	return channel.Name, nil
-//}
+func (x *ChannelIndex) publicChannels() ([]*model.Channel, error) {
+	if x.publicLoaded {
+		return x.public, nil
+	}
+
+	page := 0
+	perPage := 100
+	for {
+		channels, appErr := x.c.API.GetPublicChannelsForTeam(x.teamID, page, perPage)
+		if appErr != nil {
+			return nil, apiError("list public channels", "", appErr)
+		}
+		if len(channels) == 0 {
+			break
+		}
+		for _, channel := range channels {
+			x.remember(channel)
+		}
+		x.public = append(x.public, channels...)
+		page++
+	}
+	x.publicLoaded = true
+
+	return x.public, nil
+}
+
+func notFoundChannel(entry config.Channel, detail string) error {
+	return NewError(KindNotFound, "resolve channel", entry.String(), fmt.Errorf("%w (%s)", ErrChannelNotFound, detail))
+}
+
+func (x *ChannelIndex) remember(channel *model.Channel) {
+	x.byID[channel.Id] = channel
+	x.byName[channel.Name] = channel
+}
@@ -0,0 +1,103 @@
+package business
+
+import (
+	"fmt"
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"testing"
+)
+
+// baselineCheck replays the lookups a team-wide check made before the channel
+// index existed: a membership request per public channel, one channel request
+// per sidebar entry and one name lookup per configured channel, for every user.
+func baselineCheck(f *fixture) {
+	api := f.api
+	users, _ := api.GetUsersInTeam(f.team.Id, 0, 1000)
+	for _, user := range users {
+		categories, _ := api.GetChannelSidebarCategories(user.Id, f.team.Id)
+
+		channels, _ := api.GetPublicChannelsForTeam(f.team.Id, 0, 10000)
+		for _, channel := range channels {
+			_, _ = api.GetChannelMember(channel.Id, user.Id)
+		}
+		for _, category := range config.PublicStructure() {
+			for _, entry := range category.Channels {
+				_, _ = api.GetChannelByName(f.team.Id, entry.Name, false)
+			}
+		}
+
+		_, _ = api.GetPublicChannelsForTeam(f.team.Id, 0, 10000)
+		_, _ = api.GetChannelSidebarCategories(user.Id, f.team.Id)
+		for _, category := range categories.Categories {
+			for _, channelID := range category.Channels {
+				_, _ = api.GetChannel(channelID)
+			}
+		}
+	}
+}
+
+// baselineCalls counts the API calls of the lookups made before the index.
+func baselineCalls(f *fixture) int {
+	f.api.ResetCalls()
+	baselineCheck(f)
+	calls := f.api.Calls("")
+	f.api.ResetCalls()
+	return calls
+}
+
+// newBenchmarkFixture adds users who are members of every public channel.
+func newBenchmarkFixture(tb testing.TB, userCount int) *fixture {
+	f := newFixture(tb)
+
+	for i := 0; i < userCount; i++ {
+		user := f.addUser(fmt.Sprintf("user%d", i))
+		for _, channel := range f.channels {
+			if channel.Type == model.ChannelTypeOpen && channel.Name != "town-square" {
+				f.api.AddMember(channel.Id, user.Id)
+			}
+		}
+	}
+	f.api.ResetCalls()
+
+	return f
+}
+
+// BenchmarkCheckUserChannelStructure reports the API calls of a team-wide
+// check sharing one channel index next to those of the lookups made before the
+// index.
+func BenchmarkCheckUserChannelStructure(b *testing.B) {
+	for _, userCount := range []int{10, 100} {
+		b.Run(fmt.Sprintf("users=%d", userCount), func(b *testing.B) {
+			f := newBenchmarkFixture(b, userCount)
+			baseline := baselineCalls(f)
+
+			for i := 0; i < b.N; i++ {
+				if _, err := WrapTeam(f.c, f.team).CheckUserChannelStructure(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(f.api.Calls(""))/float64(b.N), "apicalls/op")
+			b.ReportMetric(float64(baseline), "baseline-apicalls/op")
+		})
+	}
+}
+
+// TestCheckUserChannelStructureSharesLookups checks that the channels are
+// looked up once per check, leaving two calls per member: their memberships
+// and their sidebar.
+func TestCheckUserChannelStructureSharesLookups(t *testing.T) {
+	calls := func(userCount int) int {
+		f := newBenchmarkFixture(t, userCount)
+		if _, err := WrapTeam(f.c, f.team).CheckUserChannelStructure(); err != nil {
+			t.Fatal(err)
+		}
+		return f.api.Calls("")
+	}
+
+	if perUser := (calls(20) - calls(10)) / 10; perUser != 2 {
+		t.Errorf("%d API calls per member, want 2", perUser)
+	}
+	if indexed, baseline := calls(10), baselineCalls(newBenchmarkFixture(t, 10)); indexed >= baseline {
+		t.Errorf("%d API calls, want fewer than the %d before the index", indexed, baseline)
+	}
+}
@@ -0,0 +1,180 @@
+package business
+
+import (
+	"errors"
+	"fmt"
+	"github.com/glass.plugin-anchor/server/api"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"net/http"
+	"strings"
+)
+
+type ErrorKind int
+
+const (
+	KindAPI ErrorKind = iota
+	KindNotFound
+	KindPermission
+	KindInvalid
+	KindPartial
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindPermission:
+		return "permission denied"
+	case KindInvalid:
+		return "invalid"
+	case KindPartial:
+		return "partial failure"
+	default:
+		return "API failure"
+	}
+}
+
+// Error is the error returned by the operations of this package. Op names the
+// operation and Item what it failed on. A partial failure carries the errors
+// of the failed items in Items, while the other items succeeded.
+type Error struct {
+	Kind  ErrorKind
+	Op    string
+	Item  string
+	Err   error
+	Items []*Error
+}
+
+func NewError(kind ErrorKind, op, item string, err error) *Error {
+	return &Error{Kind: kind, Op: op, Item: item, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Kind == KindPartial {
+		var failures []string
+		for _, item := range e.Items {
+			failures = append(failures, item.Error())
+		}
+		return fmt.Sprintf("%s: %d failed: %s", e.Op, len(e.Items), strings.Join(failures, "; "))
+	}
+
+	message := e.Op
+	if e.Item != "" {
+		message += " " + e.Item
+	}
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the kind of a business error, or KindAPI for other errors.
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindAPI
+}
+
+// ItemErrors returns the per-item errors of a partial failure.
+func ItemErrors(err error) []*Error {
+	var e *Error
+	if errors.As(err, &e) && e.Kind == KindPartial {
+		return e.Items
+	}
+	return nil
+}
+
+// IsTransient reports whether the error, or any item of a partial failure, is
+// one the server may not repeat: an internal error or rate limiting.
+func IsTransient(err error) bool {
+	for _, item := range ItemErrors(err) {
+		if IsTransient(item) {
+			return true
+		}
+	}
+
+	var appErr *model.AppError
+	if errors.As(err, &appErr) {
+		return transientStatus(appErr.StatusCode)
+	}
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) {
+		return transientStatus(statusErr.StatusCode)
+	}
+	return false
+}
+
+// apiError classifies an error returned by the plugin API. It returns nil for
+// a nil *model.AppError, so it can wrap API results directly.
+func apiError(op, item string, appErr *model.AppError) error {
+	if appErr == nil {
+		return nil
+	}
+	return NewError(statusKind(appErr.StatusCode), op, item, appErr)
+}
+
+// restError classifies an error returned by the REST API like apiError. Errors
+// without an answer of the server are KindAPI.
+func restError(op, item string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) {
+		return NewError(statusKind(statusErr.StatusCode), op, item, err)
+	}
+	return NewError(KindAPI, op, item, err)
+}
+
+func statusKind(statusCode int) ErrorKind {
+	switch statusCode {
+	case http.StatusNotFound:
+		return KindNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return KindPermission
+	case http.StatusBadRequest:
+		return KindInvalid
+	}
+	return KindAPI
+}
+
+func transientStatus(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+}
+
+// failures collects the per-item errors of an operation on many items.
+type failures struct {
+	op    string
+	items []*Error
+}
+
+func (f *failures) add(item string, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = NewError(KindAPI, f.op, item, err)
+	}
+	f.items = append(f.items, e)
+}
+
+func (f *failures) merge(err error) {
+	if items := ItemErrors(err); items != nil {
+		f.items = append(f.items, items...)
+	} else if err != nil {
+		f.add("", err)
+	}
+}
+
+// err returns a partial failure if any item failed, nil otherwise.
+func (f *failures) err() error {
+	if len(f.items) == 0 {
+		return nil
+	}
+	return &Error{Kind: KindPartial, Op: f.op, Items: f.items}
+}
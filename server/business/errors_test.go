@@ -0,0 +1,82 @@
+package business
+
+import (
+	"errors"
+	"github.com/glass.plugin-anchor/server/api"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"net/http"
+	"testing"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		status int
+		kind   ErrorKind
+	}{
+		{http.StatusNotFound, KindNotFound},
+		{http.StatusForbidden, KindPermission},
+		{http.StatusUnauthorized, KindPermission},
+		{http.StatusBadRequest, KindInvalid},
+		{http.StatusInternalServerError, KindAPI},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			appErr := model.NewAppError("GetUser", "app.user.get.app_error", nil, "", test.status)
+			err := apiError("find user", "skipper", appErr)
+
+			if kind := KindOf(err); kind != test.kind {
+				t.Errorf("kind = %s, want %s", kind, test.kind)
+			}
+			if !errors.Is(err, appErr) {
+				t.Errorf("error does not wrap the app error")
+			}
+		})
+	}
+
+	if err := apiError("find user", "skipper", nil); err != nil {
+		t.Errorf("apiError(nil) = %v, want nil", err)
+	}
+}
+
+func TestRestError(t *testing.T) {
+	err := restError("order sidebar categories of user", "skipper", &api.StatusError{StatusCode: http.StatusForbidden})
+	if kind := KindOf(err); kind != KindPermission {
+		t.Errorf("kind = %s, want %s", kind, KindPermission)
+	}
+
+	err = restError("order sidebar categories of user", "skipper", &api.StatusError{StatusCode: http.StatusTooManyRequests})
+	if !IsTransient(err) {
+		t.Errorf("%v is not transient", err)
+	}
+
+	if err := restError("order sidebar categories of user", "skipper", nil); err != nil {
+		t.Errorf("restError(nil) = %v, want nil", err)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	failed := failures{op: "onboard skipper"}
+	if err := failed.err(); err != nil {
+		t.Fatalf("err() = %v without failures, want nil", err)
+	}
+
+	failed.add("Kaag Cup", NewError(KindNotFound, "resolve channel", "Kaag Cup", nil))
+	failed.merge(&Error{Kind: KindPartial, Op: "create sidebar categories", Items: []*Error{
+		NewError(KindAPI, "create sidebar category", "Racing", nil),
+	}})
+	failed.merge(errors.New("connection reset"))
+
+	err := failed.err()
+	if kind := KindOf(err); kind != KindPartial {
+		t.Fatalf("kind = %s, want %s", kind, KindPartial)
+	}
+
+	items := ItemErrors(err)
+	if len(items) != 3 {
+		t.Fatalf("%d item errors, want 3", len(items))
+	}
+	if items[0].Kind != KindNotFound || items[1].Item != "Racing" || items[2].Kind != KindAPI {
+		t.Errorf("item errors = %v", items)
+	}
+}
@@ -0,0 +1,106 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/fakeapi"
+	"github.com/glass.plugin-anchor/server/models"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"testing"
+)
+
+// fixture is a team holding every channel of the structure, run by an admin.
+type fixture struct {
+	api      *fakeapi.API
+	c        *models.Context
+	team     *model.Team
+	admin    *model.User
+	channels map[string]*model.Channel // by name
+}
+
+func newFixture(tb testing.TB) *fixture {
+	tb.Helper()
+
+	api := fakeapi.New()
+	f := &fixture{
+		api:      api,
+		team:     api.AddTeam("esc"),
+		admin:    api.AddUser("admin", model.SystemAdminRoleId+" "+model.SystemUserRoleId),
+		channels: make(map[string]*model.Channel),
+	}
+
+	for _, category := range config.Structure {
+		for _, entry := range category.Channels {
+			channelType := model.ChannelTypeOpen
+			if entry.Private {
+				channelType = model.ChannelTypePrivate
+			}
+			f.channels[entry.Name] = api.AddChannel(f.team.Id, entry.Name, entry.DisplayName, channelType)
+		}
+	}
+
+	api.AddTeamMember(f.team.Id, f.admin.Id)
+	bot, _ := api.CreateBot(&model.Bot{Username: config.BotUsername, DisplayName: config.BotDisplayName})
+	f.c = &models.Context{Team: f.team, User: f.admin, API: api, Rest: api, BotID: bot.UserId}
+
+	return f
+}
+
+// addUser creates a team member who is only in Town Square.
+func (f *fixture) addUser(username string) *model.User {
+	user := f.api.AddUser(username, model.SystemUserRoleId)
+	f.api.AddTeamMember(f.team.Id, user.Id)
+	f.api.AddMember(f.channels["town-square"].Id, user.Id)
+	return user
+}
+
+func (f *fixture) sideBar(tb testing.TB, user *model.User) *SideBar {
+	tb.Helper()
+
+	s, err := NewSideBar(WrapUser(f.c, user))
+	if err != nil {
+		tb.Fatalf("NewSideBar: %v", err)
+	}
+	return s
+}
+
+// onboard onboards the user and fails the test on any error.
+func (f *fixture) onboard(tb testing.TB, user *model.User) *OnboardingResult {
+	tb.Helper()
+
+	result, err := f.sideBar(tb, user).CheckAndJoinDefaultChannelStructure()
+	if err != nil {
+		tb.Fatalf("CheckAndJoinDefaultChannelStructure: %v", err)
+	}
+	return result
+}
+
+// category returns the user's sidebar category with the given name, or nil.
+func (f *fixture) category(user *model.User, name string) *model.SidebarCategoryWithChannels {
+	for _, category := range f.api.Categories(user.Id, f.team.Id) {
+		if category.DisplayName == name {
+			return category
+		}
+	}
+	return nil
+}
+
+func (f *fixture) categoryNames(user *model.User) []string {
+	var names []string
+	for _, category := range f.api.Categories(user.Id, f.team.Id) {
+		names = append(names, category.DisplayName)
+	}
+	return names
+}
+
+// configuredChannelIDs lists the IDs of the channels of a category meant for
+// every member, which are its public channels without an audience.
+func (f *fixture) configuredChannelIDs(category string) []string {
+	var ids []string
+	managed, _ := config.FindCategory(category)
+	for _, entry := range managed.Channels {
+		if !entry.Private && entry.Audience.Everyone() {
+			ids = append(ids, f.channels[entry.Name].Id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,172 @@
+package business
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/glass.plugin-anchor/server/models"
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+const (
+	cleanupQueueKey = "cleanup_queue"
+	// expectationTTL is how long the plugin waits for the membership message
+	// of a channel it added a user to, which the server may post later
+	expectationTTL = 60
+	// queueAttempts limits the retries of concurrent updates of the queue
+	queueAttempts = 5
+)
+
+var errQueueContended = errors.New("the queue kept changing")
+
+// QueuedPost is a membership message the plugin caused, to be deleted by
+// CleanQueuedPosts.
+type QueuedPost struct {
+	ChannelID string `json:"channel_id"`
+	PostID    string `json:"post_id"`
+	Message   string `json:"message"`
+}
+
+// IsMembershipPost tells whether the post is a system message about a user
+// joining or being added to a channel.
+func IsMembershipPost(post *model.Post) bool {
+	return post.Type == model.PostTypeJoinChannel || post.Type == model.PostTypeAddToChannel
+}
+
+// ExpectMembershipPost records that the plugin is about to add the user to
+// the channel, so that the hooks recognize the message this causes.
+func ExpectMembershipPost(c *models.Context, channelID, userID string) error {
+	_, appErr := c.API.KVSetWithOptions(expectationKey(channelID, userID), []byte{1}, model.PluginKVSetOptions{ExpireInSeconds: expectationTTL})
+	return apiError("expect membership message in channel", channelID, appErr)
+}
+
+// TakeExpectedMembershipPost tells whether the plugin caused the membership
+// message, and forgets about it so that later messages pass.
+func TakeExpectedMembershipPost(c *models.Context, post *model.Post) (bool, error) {
+	if !IsMembershipPost(post) {
+		return false, nil
+	}
+
+	key := expectationKey(post.ChannelId, membershipSubject(post))
+	data, appErr := c.API.KVGet(key)
+	if appErr != nil {
+		return false, apiError("look up membership message in channel", post.ChannelId, appErr)
+	}
+	if data == nil {
+		return false, nil
+	}
+	return true, apiError("forget membership message in channel", post.ChannelId, c.API.KVDelete(key))
+}
+
+// CleanupQueue returns the queued posts, oldest first.
+func CleanupQueue(c *models.Context) ([]QueuedPost, error) {
+	queue, _, err := loadCleanupQueue(c)
+	return queue, err
+}
+
+// QueueCleanup queues the post for deletion by CleanQueuedPosts.
+func QueueCleanup(c *models.Context, post *model.Post) error {
+	return updateCleanupQueue(c, func(queue []QueuedPost) []QueuedPost {
+		return append(queue, QueuedPost{ChannelID: post.ChannelId, PostID: post.Id, Message: post.Message})
+	})
+}
+
+// CleanQueuedPosts deletes the queued posts. Posts that could not be deleted
+// stay queued.
+func CleanQueuedPosts(c *models.Context, DryRun bool) (*CleanupResult, error) {
+	queue, err := CleanupQueue(c)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CleanupResult{DryRun: DryRun}
+	for _, queued := range queue {
+		result.Matched = append(result.Matched, &model.Post{Id: queued.PostID, ChannelId: queued.ChannelID, Message: queued.Message})
+	}
+	if DryRun {
+		return result, nil
+	}
+
+	failed := failures{op: "delete queued posts"}
+	done := make(map[string]bool)
+	for _, queued := range queue {
+		appErr := c.API.DeletePost(queued.PostID)
+		if err := apiError("delete post", queued.Message, appErr); err != nil && KindOf(err) != KindNotFound {
+			failed.add(queued.Message, err)
+			continue
+		}
+		if appErr == nil {
+			result.Deleted++
+		}
+		done[queued.PostID] = true
+	}
+
+	failed.merge(updateCleanupQueue(c, func(queue []QueuedPost) []QueuedPost {
+		var remaining []QueuedPost
+		for _, queued := range queue {
+			if !done[queued.PostID] {
+				remaining = append(remaining, queued)
+			}
+		}
+		return remaining
+	}))
+
+	return result, failed.err()
+}
+
+// private
+
+func expectationKey(channelID, userID string) string {
+	return "membership_" + channelID + "_" + userID
+}
+
+// membershipSubject returns the ID of the user who joined or was added.
+func membershipSubject(post *model.Post) string {
+	if addedUserID, ok := post.GetProp(model.PostPropsAddedUserId).(string); ok && addedUserID != "" {
+		return addedUserID
+	}
+	return post.UserId
+}
+
+func loadCleanupQueue(c *models.Context) ([]QueuedPost, []byte, error) {
+	var queue []QueuedPost
+
+	data, appErr := c.API.KVGet(cleanupQueueKey)
+	if appErr != nil {
+		return nil, nil, apiError("load cleanup queue", "", appErr)
+	}
+	if data == nil {
+		return queue, nil, nil
+	}
+
+	if err := json.Unmarshal(data, &queue); err != nil {
+		return nil, nil, NewError(KindInvalid, "load cleanup queue", "", err)
+	}
+	return queue, data, nil
+}
+
+// updateCleanupQueue changes the queue with compare-and-set, as the hooks
+// queue posts concurrently.
+func updateCleanupQueue(c *models.Context, update func([]QueuedPost) []QueuedPost) error {
+	for attempt := 0; attempt < queueAttempts; attempt++ {
+		queue, old, err := loadCleanupQueue(c)
+		if err != nil {
+			return err
+		}
+
+		var data []byte
+		if queue = update(queue); len(queue) > 0 {
+			if data, err = json.Marshal(queue); err != nil {
+				return NewError(KindInvalid, "save cleanup queue", "", err)
+			}
+		}
+
+		saved, appErr := c.API.KVSetWithOptions(cleanupQueueKey, data, model.PluginKVSetOptions{Atomic: true, OldValue: old})
+		if appErr != nil {
+			return apiError("save cleanup queue", "", appErr)
+		}
+		if saved {
+			return nil
+		}
+	}
+	return NewError(KindAPI, "save cleanup queue", "", errQueueContended)
+}
@@ -0,0 +1,70 @@
+package business
+
+import (
+	"github.com/mattermost/mattermost-server/v6/model"
+	"testing"
+)
+
+func TestTakeExpectedMembershipPost(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	channel := f.channels["club-news"]
+
+	if err := ExpectMembershipPost(f.c, channel.Id, user.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	added := &model.Post{ChannelId: channel.Id, UserId: f.c.BotID, Type: model.PostTypeAddToChannel}
+	added.AddProp(model.PostPropsAddedUserId, user.Id)
+	other := &model.Post{ChannelId: channel.Id, UserId: f.admin.Id, Type: model.PostTypeJoinChannel}
+
+	for _, test := range []struct {
+		name     string
+		post     *model.Post
+		expected bool
+	}{
+		{"another user's message", other, false},
+		{"the expected message", added, true},
+		{"the same message again", added, false},
+		{"a regular post", &model.Post{ChannelId: channel.Id, UserId: user.Id}, false},
+	} {
+		expected, err := TakeExpectedMembershipPost(f.c, test.post)
+		if err != nil || expected != test.expected {
+			t.Errorf("%s: expected = %t, %v, want %t", test.name, expected, err, test.expected)
+		}
+	}
+}
+
+func TestCleanQueuedPosts(t *testing.T) {
+	f := newFixture(t)
+	channel := f.channels["club-news"]
+	queued := f.api.AddPost(channel.Id, f.c.BotID, model.PostTypeAddToChannel, "skipper added to the channel by anchor.")
+	gone := &model.Post{Id: model.NewId(), ChannelId: channel.Id, Message: "bosun added to the channel by anchor."}
+	f.api.AddPost(channel.Id, f.admin.Id, model.PostTypeDefault, "The club house opens at ten.")
+
+	for _, post := range []*model.Post{queued, gone} {
+		if err := QueueCleanup(f.c, post); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := CleanQueuedPosts(f.c, true)
+	if err != nil || len(result.Matched) != 2 || len(f.api.Posts(channel.Id)) != 2 {
+		t.Fatalf("dry run: result = %+v, %v", result, err)
+	}
+
+	result, err = CleanQueuedPosts(f.c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Deleted != 1 {
+		t.Errorf("deleted %d posts, want 1", result.Deleted)
+	}
+	if posts := f.api.Posts(channel.Id); len(posts) != 1 || posts[0].Type != model.PostTypeDefault {
+		t.Errorf("remaining posts = %v, want the regular post", posts)
+	}
+
+	if queue, err := CleanupQueue(f.c); err != nil || len(queue) != 0 {
+		t.Errorf("queue = %v, %v after cleanup, want it empty", queue, err)
+	}
+}
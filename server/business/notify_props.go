@@ -0,0 +1,88 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+// NotifyDrift is a channel in which the notification preferences of a member
+// differ from the preset of the structure.
+type NotifyDrift struct {
+	Channel  string
+	Expected map[string]string
+	Actual   map[string]string
+}
+
+// ReconcileNotifyProps compares the notification preferences of the user in
+// the channels of the categories having a preset, and resets them to the
+// preset if reset is set. Channels the user is not a member of are skipped.
+// Channels that could not be checked or reset are reported as a partial
+// failure.
+func (u *User) ReconcileNotifyProps(categories []config.Category, reset bool) ([]NotifyDrift, error) {
+	var drifts []NotifyDrift
+	failed := failures{op: "reconcile notification preferences"}
+
+	for _, category := range categories {
+		for _, entry := range category.Channels {
+			if entry.Notify.IsZero() {
+				continue
+			}
+			displayName := entry.String()
+
+			channel, err := u.channels.Resolve(entry)
+			if err != nil {
+				failed.add(displayName, err)
+				continue
+			}
+			isMember, err := u.channels.IsMember(u.Id, channel.Id)
+			if err != nil {
+				failed.add(displayName, err)
+				continue
+			}
+			if !isMember {
+				continue
+			}
+
+			member, appErr := u.c.API.GetChannelMember(channel.Id, u.Id)
+			if appErr != nil {
+				failed.add(displayName, apiError("get channel member", displayName, appErr))
+				continue
+			}
+			drift, drifted := notifyDrift(displayName, entry.Notify, member.NotifyProps)
+			if !drifted {
+				continue
+			}
+			drifts = append(drifts, drift)
+
+			if reset {
+				if err := u.setNotifyProps(channel, entry.Notify); err != nil {
+					failed.add(displayName, err)
+				}
+			}
+		}
+	}
+
+	return drifts, failed.err()
+}
+
+// private
+
+func (u *User) setNotifyProps(channel *model.Channel, notify config.NotifyProps) error {
+	_, appErr := u.c.API.UpdateChannelMemberNotifications(channel.Id, u.Id, notify.Props())
+	return apiError("set notification preferences in channel", channel.DisplayName, appErr)
+}
+
+// static
+
+// notifyDrift compares the preset with the notify props of a member.
+func notifyDrift(channel string, notify config.NotifyProps, props model.StringMap) (NotifyDrift, bool) {
+	drift := NotifyDrift{Channel: channel, Expected: make(map[string]string), Actual: make(map[string]string)}
+
+	for key, value := range notify.Props() {
+		if props[key] != value {
+			drift.Expected[key] = value
+			drift.Actual[key] = props[key]
+		}
+	}
+	return drift, len(drift.Expected) > 0
+}
@@ -0,0 +1,103 @@
+package business
+
+import (
+	"github.com/mattermost/mattermost-server/v6/model"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJoinPresetsNotifications(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+
+	result := f.onboard(t, user)
+
+	carPool, _ := f.api.GetChannelMember(f.channels["car-pool"].Id, user.Id)
+	if carPool.NotifyProps[model.DesktopNotifyProp] != model.ChannelNotifyMention || carPool.NotifyProps[model.MarkUnreadNotifyProp] != model.ChannelMarkUnreadMention {
+		t.Errorf("car pool notify props = %v, want the quiet preset", carPool.NotifyProps)
+	}
+	clubNews, _ := f.api.GetChannelMember(f.channels["club-news"].Id, user.Id)
+	if clubNews.NotifyProps[model.DesktopNotifyProp] != model.ChannelNotifyDefault {
+		t.Errorf("club news notify props = %v, want the defaults", clubNews.NotifyProps)
+	}
+	if len(result.Join.Notified) != 3 {
+		t.Errorf("notified = %v, want the 3 quiet channels", result.Join.Notified)
+	}
+}
+
+func TestRetriedJoinKeepsNotifiedChannels(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	// Fail adding to Off-Topic, after Market Place and Car Pool were joined
+	f.api.Fail("AddUserToChannel", http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusServiceUnavailable)
+
+	result := f.onboard(t, user)
+
+	if result.Steps[0].Attempts != 2 {
+		t.Fatalf("join step = %+v, want done after a retry", result.Steps[0])
+	}
+	if expected := []string{"Market Place", "Car Pool", "Off-Topic"}; !reflect.DeepEqual(result.Join.Notified, expected) {
+		t.Errorf("notified = %v, want %v", result.Join.Notified, expected)
+	}
+}
+
+func TestPresetAppliedAfterTransientError(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.api.Fail("UpdateChannelMemberNotifications", http.StatusInternalServerError)
+
+	result := f.onboard(t, user)
+
+	if join := result.Steps[0]; join.State != StepDone || join.Attempts != 2 {
+		t.Fatalf("join step = %+v, want done after a retry", join)
+	}
+	marketPlace, _ := f.api.GetChannelMember(f.channels["market-place"].Id, user.Id)
+	if marketPlace.NotifyProps[model.PushNotifyProp] != model.ChannelNotifyMention {
+		t.Errorf("market place notify props = %v, want the quiet preset", marketPlace.NotifyProps)
+	}
+	if expected := []string{"Car Pool", "Off-Topic", "Market Place"}; !reflect.DeepEqual(result.Join.Notified, expected) {
+		t.Errorf("notified = %v, want %v", result.Join.Notified, expected)
+	}
+}
+
+func TestReconcileNotifyProps(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.onboard(t, user)
+	carPool := f.channels["car-pool"].Id
+	f.api.UpdateChannelMemberNotifications(carPool, user.Id, map[string]string{model.PushNotifyProp: model.ChannelNotifyAll})
+
+	u := WrapUser(f.c, user)
+	structure, err := u.Structure()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drifts, err := u.ReconcileNotifyProps(structure, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 1 || drifts[0].Channel != "Car Pool" || drifts[0].Actual[model.PushNotifyProp] != model.ChannelNotifyAll {
+		t.Fatalf("drifts = %+v, want push of car pool", drifts)
+	}
+
+	f.api.Fail("UpdateChannelMemberNotifications", http.StatusForbidden)
+	if _, err := u.ReconcileNotifyProps(structure, true); KindOf(err) != KindPartial {
+		t.Errorf("err = %v, want a partial failure", err)
+	}
+
+	if _, err := u.ReconcileNotifyProps(structure, true); err != nil {
+		t.Fatal(err)
+	}
+	if drifts, _ := u.ReconcileNotifyProps(structure, false); len(drifts) != 0 {
+		t.Errorf("drifts after reset = %+v, want none", drifts)
+	}
+}
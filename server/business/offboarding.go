@@ -0,0 +1,135 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+// OffboardingResult lists what was taken away from a leaving member.
+type OffboardingResult struct {
+	RemovedChannels   []string
+	DeletedCategories []string
+	Deactivated       bool
+}
+
+// Offboard removes the user from the channels of the structure, public and
+// private, except for the default channel that cannot be left, and from the
+// other private channels they are a member of in any of their teams. It
+// deletes the managed sidebar categories and deactivates the account if asked
+// to. What was removed is recorded in the audit trail in the name of the actor.
+func (s *SideBar) Offboard(actor string, deactivate bool) (*OffboardingResult, error) {
+	result := &OffboardingResult{}
+	failed := failures{op: "offboard " + s.User.Username}
+
+	for _, category := range config.Structure {
+		for _, entry := range category.Channels {
+			channel, err := s.u.channels.Resolve(entry)
+			if KindOf(err) == KindNotFound {
+				// A channel that does not exist has no members to remove
+				continue
+			}
+			if err != nil {
+				failed.add(entry.String(), err)
+				continue
+			}
+			if channel.Name == model.DefaultChannelName {
+				continue
+			}
+
+			isMember, err := s.u.channels.IsMember(s.User.Id, channel.Id)
+			if err != nil {
+				failed.add(entry.String(), err)
+				continue
+			}
+			if !isMember {
+				continue
+			}
+
+			if appErr := s.c.API.DeleteChannelMember(channel.Id, s.User.Id); appErr != nil {
+				failed.add(entry.String(), apiError("remove user from channel", entry.String(), appErr))
+				continue
+			}
+			s.u.channels.RemoveMember(s.User.Id, channel.Id)
+			result.RemovedChannels = append(result.RemovedChannels, entry.String())
+		}
+	}
+
+	failed.merge(s.leavePrivateChannels(result))
+
+	deleted, err := s.DeleteAllSidebarCategories(false)
+	failed.merge(err)
+	result.DeletedCategories = deleted
+
+	if deactivate {
+		if appErr := s.c.API.UpdateUserActive(s.User.Id, false); appErr != nil {
+			failed.add(s.User.Username, apiError("deactivate user", s.User.Username, appErr))
+		} else {
+			result.Deactivated = true
+		}
+	}
+
+	failed.merge(RecordAudit(s.c, AuditEntry{
+		Actor:   actor,
+		Action:  "offboard",
+		Subject: s.User.Username,
+		Details: result.details(),
+	}))
+
+	return result, failed.err()
+}
+
+// private
+
+// leavePrivateChannels removes the user from the private channels of all of
+// their teams. Channels of other teams are named with their team.
+func (s *SideBar) leavePrivateChannels(result *OffboardingResult) error {
+	failed := failures{op: "leave private channels"}
+
+	teams, appErr := s.c.API.GetTeamsForUser(s.User.Id)
+	if appErr != nil {
+		return apiError("list teams of user", s.User.Username, appErr)
+	}
+
+	for _, team := range teams {
+		channels, appErr := s.c.API.GetChannelsForTeamForUser(team.Id, s.User.Id, false)
+		if appErr != nil {
+			failed.add(team.Name, apiError("get channels of user in team", team.Name, appErr))
+			continue
+		}
+
+		for _, channel := range channels {
+			if channel.Type != model.ChannelTypePrivate {
+				continue
+			}
+			name := channel.DisplayName
+			if team.Id != s.c.Team.Id {
+				name += " (" + team.DisplayName + ")"
+			}
+
+			if appErr := s.c.API.DeleteChannelMember(channel.Id, s.User.Id); appErr != nil {
+				failed.add(name, apiError("remove user from channel", name, appErr))
+				continue
+			}
+			if team.Id == s.c.Team.Id {
+				s.u.channels.RemoveMember(s.User.Id, channel.Id)
+			}
+			result.RemovedChannels = append(result.RemovedChannels, name)
+		}
+	}
+
+	return failed.err()
+}
+
+func (r *OffboardingResult) details() []string {
+	var details []string
+	for _, channel := range r.RemovedChannels {
+		details = append(details, "removed from channel "+channel)
+	}
+	for _, category := range r.DeletedCategories {
+		details = append(details, "deleted category "+category)
+	}
+	if r.Deactivated {
+		details = append(details, "deactivated account")
+	}
+	return details
+}
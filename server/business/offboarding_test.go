@@ -0,0 +1,64 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/utils"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"testing"
+)
+
+func TestOffboard(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.onboard(t, user)
+	f.api.AddMember(f.channels["committee"].Id, user.Id)
+	boatyard := f.api.AddChannel(f.team.Id, "boatyard", "Boatyard", model.ChannelTypePrivate)
+	f.api.AddMember(boatyard.Id, user.Id)
+
+	// Private and public channels in another team
+	ops := f.api.AddTeam("ops")
+	f.api.AddTeamMember(ops.Id, user.Id)
+	opsPrivate := f.api.AddChannel(ops.Id, "rescue", "Rescue", model.ChannelTypePrivate)
+	opsPublic := f.api.AddChannel(ops.Id, "lounge", "Lounge", model.ChannelTypeOpen)
+	f.api.AddMember(opsPrivate.Id, user.Id)
+	f.api.AddMember(opsPublic.Id, user.Id)
+
+	result, err := f.sideBar(t, user).Offboard("admin", true)
+	if err != nil {
+		t.Fatalf("Offboard: %v", err)
+	}
+
+	for name, channel := range f.channels {
+		if member := f.api.IsMember(channel.Id, user.Id); member != (name == "town-square") {
+			t.Errorf("member of %s = %t after offboarding", name, member)
+		}
+	}
+	if f.api.IsMember(boatyard.Id, user.Id) || f.api.IsMember(opsPrivate.Id, user.Id) {
+		t.Errorf("still a member of private channels outside of the structure")
+	}
+	if !f.api.IsMember(opsPublic.Id, user.Id) {
+		t.Errorf("removed from a public channel of another team")
+	}
+	if !utils.Contains(result.RemovedChannels, "Rescue (ops)") {
+		t.Errorf("removed channels = %v, want Rescue of ops", result.RemovedChannels)
+	}
+	for _, categoryName := range config.CategoryNames() {
+		if f.category(user, categoryName) != nil {
+			t.Errorf("category %s was not deleted", categoryName)
+		}
+	}
+	if deactivated, _ := f.api.GetUser(user.Id); !result.Deactivated || deactivated.DeleteAt == 0 {
+		t.Errorf("account was not deactivated")
+	}
+
+	entries, err := AuditTrail(f.c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Actor != "admin" || entries[0].Subject != "skipper" {
+		t.Fatalf("audit trail = %+v, want one offboarding of skipper by admin", entries)
+	}
+	if expected := len(result.RemovedChannels) + len(result.DeletedCategories) + 1; len(entries[0].Details) != expected {
+		t.Errorf("%d details recorded, want %d", len(entries[0].Details), expected)
+	}
+}
@@ -0,0 +1,243 @@
+package business
+
+import (
+	"encoding/json"
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/models"
+	"github.com/glass.plugin-anchor/server/utils"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"time"
+)
+
+// Onboarding steps, in the order they run. Every step can be repeated without
+// changing the outcome.
+const (
+	StepJoinChannels     = "join_channels"
+	StepEnsureCategories = "ensure_categories"
+	StepAssignChannels   = "assign_channels"
+	StepOrder            = "order"
+	StepWelcome          = "welcome"
+)
+
+type StepState string
+
+const (
+	StepDone    StepState = "done"
+	StepSkipped StepState = "skipped" // completed by an earlier run
+	StepPartial StepState = "partial" // some channels or categories failed
+	StepFailed  StepState = "failed"
+	StepPending StepState = "pending" // not run because an earlier step failed
+)
+
+// StepStatus is the outcome of one onboarding step. Attempts counts the tries
+// including retries after transient errors.
+type StepStatus struct {
+	Step     string
+	State    StepState
+	Attempts int
+	Err      error
+}
+
+// OnboardingResult collects the outcome of the onboarding steps.
+type OnboardingResult struct {
+	Steps       []StepStatus
+	Join        *JoinResult
+	Assignments []CategoryAssignment
+	Reorder     *ReorderResult
+	Welcome     *model.Post
+
+	joined []string // by this run and the runs it resumes
+}
+
+// OnboardingState records the steps completed by an unfinished onboarding, so
+// that a re-run resumes after them. It is kept in the KV store and removed
+// once every step completed.
+type OnboardingState struct {
+	Completed []string `json:"completed"`
+	Joined    []string `json:"joined,omitempty"`
+}
+
+// sleep waits between retries; tests replace it to run without waiting.
+var sleep = time.Sleep
+
+type onboardingStep struct {
+	name string
+	run  func(s *SideBar, result *OnboardingResult) error
+}
+
+var onboardingSteps = []onboardingStep{
+	{StepJoinChannels, func(s *SideBar, result *OnboardingResult) error {
+		structure, err := s.u.Structure()
+		if err != nil {
+			return err
+		}
+		join, err := s.u.JoinMissingChannels(structure, result.Join)
+		result.Join = mergeJoinResults(result.Join, join)
+		if result.Join != nil {
+			result.joined = appendUnique(result.joined, result.Join.Joined...)
+		}
+		return err
+	}},
+	{StepEnsureCategories, func(s *SideBar, result *OnboardingResult) error {
+		structure, err := s.u.Structure()
+		if err != nil {
+			return err
+		}
+		return s.createMissingSidebarCategories(structure)
+	}},
+	{StepAssignChannels, func(s *SideBar, result *OnboardingResult) error {
+		structure, err := s.u.Structure()
+		if err != nil {
+			return err
+		}
+		result.Assignments, err = s.assignChannelsToCategories(structure)
+		return err
+	}},
+	{StepOrder, func(s *SideBar, result *OnboardingResult) error {
+		var err error
+		result.Reorder, err = s.ReorderSidebarCategories()
+		return err
+	}},
+	{StepWelcome, func(s *SideBar, result *OnboardingResult) error {
+		// Only members who joined channels are welcomed, once
+		if !config.SendWelcome || len(result.joined) == 0 || result.Welcome != nil {
+			return nil
+		}
+		var err error
+		result.Welcome, err = s.SendWelcome(result.joined)
+		return err
+	}},
+}
+
+func onboardingKey(teamID, userID string) string {
+	return "onboarding_" + teamID + "_" + userID
+}
+
+func LoadOnboardingState(c *models.Context, teamID, userID string) (*OnboardingState, error) {
+	state := &OnboardingState{}
+
+	data, appErr := c.API.KVGet(onboardingKey(teamID, userID))
+	if appErr != nil {
+		return nil, apiError("load onboarding state of user", userID, appErr)
+	}
+	if data == nil {
+		return state, nil
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, NewError(KindInvalid, "load onboarding state of user", userID, err)
+	}
+	return state, nil
+}
+
+func SaveOnboardingState(c *models.Context, teamID, userID string, state *OnboardingState) error {
+	if len(state.Completed) == 0 {
+		return apiError("clear onboarding state of user", userID, c.API.KVDelete(onboardingKey(teamID, userID)))
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return NewError(KindInvalid, "save onboarding state of user", userID, err)
+	}
+	return apiError("save onboarding state of user", userID, c.API.KVSet(onboardingKey(teamID, userID), data))
+}
+
+// members
+
+// CheckAndJoinDefaultChannelStructure onboards the user: it joins the channels
+// of the structure meant for the user, ensures the managed sidebar categories, assigns
+// the channels to them, orders the sidebar and welcomes the user with a direct
+// message from the bot.
+//
+// A step that fails for single channels or categories does not stop the
+// onboarding; a step that fails as a whole does. Transient server errors are
+// retried with backoff. Completed steps are recorded, and a re-run after a
+// failure resumes with the first step not completed.
+func (s *SideBar) CheckAndJoinDefaultChannelStructure() (*OnboardingResult, error) {
+
+	failed := failures{op: "onboard " + s.User.Username}
+
+	state, err := LoadOnboardingState(s.c, s.c.Team.Id, s.User.Id)
+	if err != nil {
+		return nil, err
+	}
+	result := &OnboardingResult{joined: state.Joined}
+
+	stopped := false
+	for _, step := range onboardingSteps {
+		status := StepStatus{Step: step.name}
+
+		switch {
+		case stopped:
+			status.State = StepPending
+		case utils.Contains(state.Completed, step.name):
+			status.State = StepSkipped
+		default:
+			status.Attempts, status.Err = s.runStep(step, result)
+			switch {
+			case status.Err == nil:
+				status.State = StepDone
+			case KindOf(status.Err) == KindPartial:
+				status.State = StepPartial
+			default:
+				status.State = StepFailed
+				stopped = true
+			}
+			failed.merge(status.Err)
+
+			// Steps that failed for some items run again on a re-run
+			if status.State == StepDone {
+				state.Completed = append(state.Completed, step.name)
+			}
+		}
+
+		result.Steps = append(result.Steps, status)
+	}
+
+	// A finished onboarding starts from the first step when run again
+	state.Joined = result.joined
+	if !stopped {
+		state.Completed, state.Joined = nil, nil
+	}
+	failed.merge(SaveOnboardingState(s.c, s.c.Team.Id, s.User.Id, state))
+
+	return result, failed.err()
+}
+
+// private
+
+// runStep runs the step until it succeeds, fails permanently or runs out of
+// attempts, doubling the wait after every transient failure.
+func (s *SideBar) runStep(step onboardingStep, result *OnboardingResult) (int, error) {
+	backoff := config.OnboardingBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := step.run(s, result)
+		if err == nil || !IsTransient(err) || attempt >= config.OnboardingAttempts {
+			return attempt, err
+		}
+
+		s.c.API.LogWarn("Retrying onboarding step", "step", step.name, "user", s.User.Username, "attempt", attempt, "error", err.Error())
+		sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// mergeJoinResults keeps the channels joined by an earlier attempt of the join
+// step, which a retry sees as channels the user is already a member of.
+func mergeJoinResults(previous, next *JoinResult) *JoinResult {
+	if previous == nil || next == nil {
+		return next
+	}
+
+	merged := &JoinResult{
+		Joined:   append(previous.Joined, next.Joined...),
+		Notified: appendUnique(previous.Notified, next.Notified...),
+	}
+	for _, channel := range next.AlreadyMember {
+		if !utils.Contains(previous.Joined, channel) {
+			merged.AlreadyMember = append(merged.AlreadyMember, channel)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,117 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOnboardingRetriesTransientErrors(t *testing.T) {
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = time.Sleep }()
+
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.api.Fail("UpdateChannelSidebarCategories", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	result := f.onboard(t, user)
+
+	ensure := result.Steps[1]
+	if ensure.Step != StepEnsureCategories || ensure.State != StepDone || ensure.Attempts != 3 {
+		t.Errorf("ensure step = %+v, want done after 3 attempts", ensure)
+	}
+	if expected := []time.Duration{config.OnboardingBackoff, 2 * config.OnboardingBackoff}; !reflect.DeepEqual(waits, expected) {
+		t.Errorf("waits = %v, want %v", waits, expected)
+	}
+}
+
+func TestOnboardingRetriesTransientOrderErrors(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.api.Fail("REST PUT", http.StatusServiceUnavailable)
+
+	result := f.onboard(t, user)
+
+	order := result.Steps[3]
+	if order.Step != StepOrder || order.State != StepDone || order.Attempts != 2 {
+		t.Errorf("order step = %+v, want done after 2 attempts", order)
+	}
+}
+
+func TestOnboardingResumesAfterFailedStep(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+
+	// The sidebar is updated once to ensure the categories, once per category
+	// to assign the channels, then to order them: fail the ordering for good
+	f.api.Fail("UpdateChannelSidebarCategories", http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusForbidden)
+
+	result, err := f.sideBar(t, user).CheckAndJoinDefaultChannelStructure()
+	if KindOf(err) != KindPartial {
+		t.Fatalf("err = %v, want a partial failure", err)
+	}
+	if expected := []StepState{StepDone, StepDone, StepDone, StepFailed, StepPending}; !reflect.DeepEqual(stepStates(result), expected) {
+		t.Fatalf("states = %v, want %v", stepStates(result), expected)
+	}
+
+	f.api.ResetCalls()
+	result = f.onboard(t, user)
+
+	if expected := []StepState{StepSkipped, StepSkipped, StepSkipped, StepDone, StepDone}; !reflect.DeepEqual(stepStates(result), expected) {
+		t.Errorf("states of the re-run = %v, want %v", stepStates(result), expected)
+	}
+	if calls := f.api.Calls("AddUserToChannel"); calls != 0 {
+		t.Errorf("channels joined again %d times", calls)
+	}
+	if result.Welcome == nil {
+		t.Errorf("no welcome for the channels joined before the failure")
+	}
+
+	state, err := LoadOnboardingState(f.c, f.team.Id, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Completed) != 0 {
+		t.Errorf("state %v kept after a finished onboarding", state.Completed)
+	}
+}
+
+func TestOnboardingRetriesPartlyFailedStep(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+
+	// Creating the first category and ordering the sidebar fail for good
+	f.api.Fail("CreateChannelSidebarCategory", http.StatusForbidden)
+	f.api.Fail("REST PUT", http.StatusForbidden)
+
+	result, err := f.sideBar(t, user).CheckAndJoinDefaultChannelStructure()
+	if KindOf(err) != KindPartial {
+		t.Fatalf("err = %v, want a partial failure", err)
+	}
+	if expected := []StepState{StepDone, StepPartial, StepPartial, StepFailed, StepPending}; !reflect.DeepEqual(stepStates(result), expected) {
+		t.Fatalf("states = %v, want %v", stepStates(result), expected)
+	}
+
+	result = f.onboard(t, user)
+
+	if expected := []StepState{StepSkipped, StepDone, StepDone, StepDone, StepDone}; !reflect.DeepEqual(stepStates(result), expected) {
+		t.Errorf("states of the re-run = %v, want %v", stepStates(result), expected)
+	}
+	if names := f.categoryNames(user); len(names) != len(config.CategoryOrder)+3 {
+		t.Errorf("categories = %v, want the %d managed ones and the system ones", names, len(config.CategoryOrder))
+	}
+}
+
+func stepStates(result *OnboardingResult) []StepState {
+	var states []StepState
+	for _, step := range result.Steps {
+		states = append(states, step.State)
+	}
+	return states
+}
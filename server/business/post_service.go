@@ -1,70 +1,204 @@
 package business
 
 import (
-	"fmt"
+	"github.com/glass.plugin-anchor/server/config"
 	"github.com/glass.plugin-anchor/server/models"
 	"github.com/mattermost/mattermost-server/v6/model"
 	"regexp"
 	"strings"
 )
 
-func CleanPosts(c *models.Context, channelID string, DryRun bool) string {
+const (
+	joinMessagePattern = "added to the channel by \\w+.$"
+	postsPerPage       = 200
+)
 
-	var result string
+// CleanupResult lists the posts matching the cleanup pattern. Deleted counts
+// the posts actually deleted, which stays zero on a dry run.
+type CleanupResult struct {
+	Pattern string
+	DryRun  bool
+	Matched []*model.Post
+	Deleted int
+}
 
-	regex := "added to the channel by \\w+.$"
+func CleanPosts(c *models.Context, channelID string, DryRun bool) (*CleanupResult, error) {
 
-	matches, err3 := findPostsMatchingRegex(c, channelID, regex)
+	result := &CleanupResult{Pattern: joinMessagePattern, DryRun: DryRun}
+
+	matches, err := findPostsMatchingRegex(c, channelID, joinMessagePattern)
+	if err != nil {
+		return nil, err
+	}
+	result.Matched = matches
 
 	c.API.LogWarn("Found matching posts:", "number", len(matches))
 
-	if err3 != nil {
-		return fmt.Sprintf("No posts found matching %s .", regex)
+	if DryRun {
+		return result, nil
 	}
 
-	messages := []string{fmt.Sprintf("Deleting %d posts.", len(matches))}
-	for _, message := range matches {
-
-		if DryRun == true {
-			result = fmt.Sprintf("%s: %s", "dry run", message.Message)
-		} else {
-			err := c.API.DeletePost(message.Id)
-			if err == nil {
-				result = fmt.Sprintf("Deleted: %s", message.Message)
-			} else {
-				result = fmt.Sprintf("%s: %s", err.Message, message.Message)
-			}
+	failed := failures{op: "delete posts"}
+	for _, post := range matches {
+		if appErr := c.API.DeletePost(post.Id); appErr != nil {
+			failed.add(post.Message, apiError("delete post", post.Message, appErr))
+			continue
 		}
+		result.Deleted++
+	}
+
+	return result, failed.err()
+}
+
+// ChannelCleanup counts the posts matched and deleted in one channel.
+type ChannelCleanup struct {
+	Channel string
+	Matched int
+	Deleted int
+}
+
+// TeamCleanupResult lists the counts of the channels scanned by CleanChannels.
+type TeamCleanupResult struct {
+	Pattern  string
+	DryRun   bool
+	Channels []ChannelCleanup
+}
 
-		messages = append(messages, result)
+// Totals returns the number of posts matched and deleted in all channels.
+func (r *TeamCleanupResult) Totals() (int, int) {
+	matched, deleted := 0, 0
+	for _, channel := range r.Channels {
+		matched += channel.Matched
+		deleted += channel.Deleted
+	}
+	return matched, deleted
+}
+
+// members
+
+// CleanChannels deletes the posts matching the cleanup pattern in the whole
+// history of the managed channels of the team, or of all its public and
+// managed channels. Posts are deleted in batches of config.CleanupBatchSize,
+// pausing config.CleanupBatchPause between batches to spare the server.
+func (t *Team) CleanChannels(allChannels bool, DryRun bool) (*TeamCleanupResult, error) {
+	result := &TeamCleanupResult{Pattern: joinMessagePattern, DryRun: DryRun}
+	failed := failures{op: "clean channels"}
+
+	channels, err := t.cleanupChannels(allChannels, &failed)
+	if err != nil {
+		return nil, err
 	}
 
-	return strings.Join(messages, "\n")
+	for _, channel := range channels {
+		matches, err := findPostsMatchingRegex(t.c, channel.Id, joinMessagePattern)
+		if err != nil {
+			failed.add(channel.DisplayName, err)
+			continue
+		}
+
+		cleanup := ChannelCleanup{Channel: channel.DisplayName, Matched: len(matches)}
+		if !DryRun {
+			cleanup.Deleted, err = deletePostBatches(t.c, matches)
+			failed.merge(err)
+		}
+		result.Channels = append(result.Channels, cleanup)
+	}
+
+	return result, failed.err()
 }
 
 // private
 
+// cleanupChannels returns the managed channels of the team, along with the
+// other public channels if allChannels is set.
+func (t *Team) cleanupChannels(allChannels bool, failed *failures) ([]*model.Channel, error) {
+	var channels []*model.Channel
+	seen := make(map[string]bool)
+
+	for _, category := range config.Structure {
+		for _, entry := range category.Channels {
+			channel, err := t.channels.Resolve(entry)
+			if err != nil {
+				failed.add(entry.String(), err)
+				continue
+			}
+			if !seen[channel.Id] {
+				seen[channel.Id] = true
+				channels = append(channels, channel)
+			}
+		}
+	}
+
+	if allChannels {
+		public, err := t.PublicChannels()
+		if err != nil {
+			return nil, err
+		}
+		for _, channel := range public {
+			if !seen[channel.Id] {
+				seen[channel.Id] = true
+				channels = append(channels, channel)
+			}
+		}
+	}
+
+	return channels, nil
+}
+
+// deletePostBatches deletes the posts in batches, pausing between them.
+func deletePostBatches(c *models.Context, posts []*model.Post) (int, error) {
+	deleted := 0
+	failed := failures{op: "delete posts"}
+
+	for start := 0; start < len(posts); start += config.CleanupBatchSize {
+		if start > 0 {
+			sleep(config.CleanupBatchPause)
+		}
+
+		end := start + config.CleanupBatchSize
+		if end > len(posts) {
+			end = len(posts)
+		}
+		for _, post := range posts[start:end] {
+			if appErr := c.API.DeletePost(post.Id); appErr != nil {
+				failed.add(post.Message, apiError("delete post", post.Message, appErr))
+				continue
+			}
+			deleted++
+		}
+	}
+
+	return deleted, failed.err()
+}
+
 func findPostsMatchingRegex(c *models.Context, channelID string, regexPattern string) ([]*model.Post, error) {
 	// Compile the regular expression
 	regex, err := regexp.Compile(regexPattern)
 	if err != nil {
-		return nil, err
+		return nil, NewError(KindInvalid, "compile pattern", regexPattern, err)
 	}
 
 	// Initialize an array to store the matching posts
 	var matchingPosts []*model.Post
 
-	// Retrieve posts from the channel
-	postList, appErr := c.API.GetPostsForChannel(channelID, 0, 1000) // Adjust limit based on needs
-	if appErr != nil {
-		return nil, appErr
-	}
+	// Retrieve the whole history of the channel, page by page
+	for page := 0; ; page++ {
+		postList, appErr := c.API.GetPostsForChannel(channelID, page, postsPerPage)
+		if appErr != nil {
+			return nil, apiError("get posts of channel", channelID, appErr)
+		}
 
-	// Loop through posts and check if they match the regular expression
-	for _, post := range postList.ToSlice() {
-		// Convert post message to lowercase for case-insensitive matching
-		if regex.MatchString(strings.ToLower(post.Message)) {
-			matchingPosts = append(matchingPosts, post)
+		posts := postList.ToSlice()
+		if len(posts) == 0 {
+			break
+		}
+
+		// Loop through posts and check if they match the regular expression
+		for _, post := range posts {
+			// Convert post message to lowercase for case-insensitive matching
+			if regex.MatchString(strings.ToLower(post.Message)) {
+				matchingPosts = append(matchingPosts, post)
+			}
 		}
 	}
 
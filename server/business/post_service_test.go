@@ -0,0 +1,93 @@
+package business
+
+import (
+	"github.com/mattermost/mattermost-server/v6/model"
+	"testing"
+	"time"
+)
+
+func TestCleanPosts(t *testing.T) {
+	tests := []struct {
+		name      string
+		dryRun    bool
+		remaining int
+	}{
+		{"dry run keeps posts", true, 3},
+		{"deletes matching posts", false, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFixture(t)
+			channel := f.channels["club-news"]
+			f.api.AddPost(channel.Id, f.admin.Id, model.PostTypeAddToChannel, "skipper added to the channel by admin.")
+			f.api.AddPost(channel.Id, f.admin.Id, model.PostTypeAddToChannel, "bosun added to the channel by admin.")
+			f.api.AddPost(channel.Id, f.admin.Id, model.PostTypeDefault, "The club house opens at ten.")
+
+			result, err := CleanPosts(f.c, channel.Id, test.dryRun)
+			if err != nil {
+				t.Fatalf("CleanPosts: %v", err)
+			}
+			if len(result.Matched) != 2 {
+				t.Errorf("%d posts matched, want 2", len(result.Matched))
+			}
+
+			posts := f.api.Posts(channel.Id)
+			if len(posts) != test.remaining {
+				t.Fatalf("%d posts remain, want %d", len(posts), test.remaining)
+			}
+			if posts[len(posts)-1].Type != model.PostTypeDefault {
+				t.Errorf("the regular post was deleted")
+			}
+		})
+	}
+}
+
+func TestCleanChannels(t *testing.T) {
+	var pauses int
+	sleep = func(time.Duration) { pauses++ }
+	defer func() { sleep = time.Sleep }()
+
+	f := newFixture(t)
+	clubNews := f.channels["club-news"]
+	crewFinder := f.channels["crew-finder"]
+	unmanaged := f.api.AddChannel(f.team.Id, "bar", "Bar", model.ChannelTypeOpen)
+
+	// More than a page of history and more than a batch of matches
+	for i := 0; i < 2*postsPerPage; i++ {
+		f.api.AddPost(clubNews.Id, f.admin.Id, model.PostTypeDefault, "The club house opens at ten.")
+		if i%3 == 0 {
+			f.api.AddPost(clubNews.Id, f.admin.Id, model.PostTypeAddToChannel, "skipper added to the channel by admin.")
+		}
+	}
+	f.api.AddPost(crewFinder.Id, f.admin.Id, model.PostTypeAddToChannel, "bosun added to the channel by admin.")
+	f.api.AddPost(unmanaged.Id, f.admin.Id, model.PostTypeAddToChannel, "bosun added to the channel by admin.")
+
+	result, err := WrapTeam(f.c, f.team).CleanChannels(false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched, deleted := result.Totals(); matched != 135 || deleted != 0 {
+		t.Errorf("dry run: matched %d, deleted %d, want 135 and 0", matched, deleted)
+	}
+
+	result, err = WrapTeam(f.c, f.team).CleanChannels(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]ChannelCleanup)
+	for _, channel := range result.Channels {
+		counts[channel.Channel] = channel
+	}
+	for name, expected := range map[string]int{"Club News": 134, "Crew Finder": 1, "Bar": 1} {
+		if counts[name].Matched != expected || counts[name].Deleted != expected {
+			t.Errorf("%s: %+v, want %d deleted", name, counts[name], expected)
+		}
+	}
+	if pauses != 2 {
+		t.Errorf("paused %d times, want between the 3 batches of Club News", pauses)
+	}
+	if posts := f.api.Posts(clubNews.Id); len(posts) != 2*postsPerPage {
+		t.Errorf("%d posts remain in Club News, want %d", len(posts), 2*postsPerPage)
+	}
+}
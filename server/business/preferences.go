@@ -0,0 +1,57 @@
+package business
+
+import (
+	"encoding/json"
+	"github.com/glass.plugin-anchor/server/models"
+)
+
+// Preferences are the per-user choices the plugin honours, kept in the KV store.
+type Preferences struct {
+	// RespectCategorySettings keeps the sorting, collapsed and muted state the
+	// user gave to managed categories instead of the configured policies.
+	RespectCategorySettings bool `json:"respect_category_settings"`
+}
+
+func preferencesKey(userID string) string {
+	return "preferences_" + userID
+}
+
+func LoadPreferences(c *models.Context, userID string) (*Preferences, error) {
+	preferences := &Preferences{}
+
+	data, appErr := c.API.KVGet(preferencesKey(userID))
+	if appErr != nil {
+		return nil, apiError("load preferences of user", userID, appErr)
+	}
+	if data == nil {
+		return preferences, nil
+	}
+
+	if err := json.Unmarshal(data, preferences); err != nil {
+		return nil, NewError(KindInvalid, "load preferences of user", userID, err)
+	}
+	return preferences, nil
+}
+
+func SavePreferences(c *models.Context, userID string, preferences *Preferences) error {
+	data, err := json.Marshal(preferences)
+	if err != nil {
+		return NewError(KindInvalid, "save preferences of user", userID, err)
+	}
+
+	return apiError("save preferences of user", userID, c.API.KVSet(preferencesKey(userID), data))
+}
+
+// members
+
+// SetRespectCategorySettings records whether the user's own category settings
+// win over the configured policies.
+func (u *User) SetRespectCategorySettings(respect bool) error {
+	preferences, err := LoadPreferences(u.c, u.Id)
+	if err != nil {
+		return err
+	}
+
+	preferences.RespectCategorySettings = respect
+	return SavePreferences(u.c, u.Id, preferences)
+}
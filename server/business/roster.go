@@ -0,0 +1,310 @@
+package business
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/models"
+	"github.com/glass.plugin-anchor/server/utils"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"io"
+	"sort"
+	"strings"
+)
+
+var errNoRoster = errors.New("no CSV file posted in this channel, attach the roster to a message first")
+
+// RosterEntry is a line of a roster: a user by name or email and the channels
+// or RosterRoles they belong to.
+type RosterEntry struct {
+	Line     int
+	User     string
+	Channels []string
+}
+
+// RosterChange lists the members to add to and to remove from a channel.
+type RosterChange struct {
+	Channel *model.Channel
+	Add     []*model.User
+	Remove  []*model.User
+}
+
+// RosterPlan is the membership of the channels of a roster compared to the
+// current state. Users and channels which do not exist, and users who are not
+// members of the team, are left out.
+type RosterPlan struct {
+	File            string
+	Changes         []RosterChange
+	UnknownUsers    []string
+	OutsideTeam     []string
+	UnknownChannels []string
+}
+
+// FindRoster returns the name and content of the latest CSV file the user
+// posted in the channel.
+func FindRoster(c *models.Context, channelID, userID string) (string, []byte, error) {
+	list, appErr := c.API.GetPostsForChannel(channelID, 0, postsPerPage)
+	if appErr != nil {
+		return "", nil, apiError("list posts of channel", channelID, appErr)
+	}
+
+	for _, postID := range list.Order {
+		post := list.Posts[postID]
+		if post.UserId != userID {
+			continue
+		}
+		for _, fileID := range post.FileIds {
+			info, appErr := c.API.GetFileInfo(fileID)
+			if appErr != nil {
+				return "", nil, apiError("get file info", fileID, appErr)
+			}
+			if !strings.EqualFold(info.Extension, "csv") {
+				continue
+			}
+			data, appErr := c.API.GetFile(fileID)
+			if appErr != nil {
+				return "", nil, apiError("read file", info.Name, appErr)
+			}
+			return info.Name, data, nil
+		}
+	}
+
+	return "", nil, NewError(KindNotFound, "find roster", "", errNoRoster)
+}
+
+// ParseRoster reads a roster with a user per line, followed by their channels
+// or roles, separated by semicolons or spaces, in one or more columns. A first
+// line starting with "username", "email" or "user" is taken as header.
+//
+//	username,channels
+//	skipper,monday-races;seven-bars
+//	bosun@example.com,racing-crew
+func ParseRoster(data []byte) ([]RosterEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var entries []RosterEntry
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, NewError(KindInvalid, "parse roster", "", err)
+		}
+
+		user := strings.TrimSpace(record[0])
+		if line == 1 && isRosterHeader(user) {
+			continue
+		}
+		if user == "" {
+			continue
+		}
+
+		entry := RosterEntry{Line: line, User: user}
+		for _, field := range record[1:] {
+			entry.Channels = append(entry.Channels, strings.FieldsFunc(field, func(r rune) bool {
+				return r == ';' || r == ' '
+			})...)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// PlanRoster compares the roster to the members of the channels it names. Users
+// listed for a channel are added, members not listed are removed, except from
+// the default channel and except bots.
+func (t *Team) PlanRoster(file string, entries []RosterEntry) (*RosterPlan, error) {
+	plan := &RosterPlan{File: file}
+
+	var channels []*model.Channel
+	listed := make(map[string]map[string]*model.User) // channel ID -> user ID -> user
+
+	for _, entry := range entries {
+		user, err := t.rosterUser(entry.User)
+		if KindOf(err) == KindNotFound {
+			plan.UnknownUsers = append(plan.UnknownUsers, fmt.Sprintf("%s (line %d)", entry.User, entry.Line))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		inTeam, err := t.hasMember(user)
+		if err != nil {
+			return nil, err
+		}
+		if !inTeam {
+			plan.OutsideTeam = append(plan.OutsideTeam, fmt.Sprintf("%s (line %d)", entry.User, entry.Line))
+			continue
+		}
+
+		for _, name := range expandRosterRoles(entry.Channels) {
+			channel, err := t.channels.Resolve(config.Channel{Name: name})
+			if KindOf(err) == KindNotFound {
+				if !utils.Contains(plan.UnknownChannels, name) {
+					plan.UnknownChannels = append(plan.UnknownChannels, name)
+				}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if listed[channel.Id] == nil {
+				listed[channel.Id] = make(map[string]*model.User)
+				channels = append(channels, channel)
+			}
+			listed[channel.Id][user.Id] = user
+		}
+	}
+
+	for _, channel := range channels {
+		change, err := t.rosterChange(channel, listed[channel.Id])
+		if err != nil {
+			return nil, err
+		}
+		if len(change.Add) > 0 || len(change.Remove) > 0 {
+			plan.Changes = append(plan.Changes, *change)
+		}
+	}
+
+	return plan, nil
+}
+
+// ApplyRoster adds the members of the plan on behalf of the bot and, if asked
+// to, removes the members not listed. Memberships which could not be changed
+// are reported as a partial failure.
+func (t *Team) ApplyRoster(plan *RosterPlan, remove bool) error {
+	failed := failures{op: "apply roster " + plan.File}
+
+	for _, change := range plan.Changes {
+		for _, user := range change.Add {
+			item := user.Username + " in " + change.Channel.Name
+			if err := ExpectMembershipPost(t.c, change.Channel.Id, user.Id); err != nil {
+				t.c.API.LogWarn("Membership message will be posted", "channel", change.Channel.Name, "error", err.Error())
+			}
+			if _, appErr := t.c.API.AddUserToChannel(change.Channel.Id, user.Id, t.c.BotID); appErr != nil {
+				failed.add(item, apiError("add user to channel", item, appErr))
+				continue
+			}
+			t.channels.AddMember(user.Id, change.Channel)
+		}
+		if !remove {
+			continue
+		}
+		for _, user := range change.Remove {
+			item := user.Username + " from " + change.Channel.Name
+			if appErr := t.c.API.DeleteChannelMember(change.Channel.Id, user.Id); appErr != nil {
+				failed.add(item, apiError("remove user from channel", item, appErr))
+				continue
+			}
+			t.channels.RemoveMember(user.Id, change.Channel.Id)
+		}
+	}
+
+	return failed.err()
+}
+
+// private
+
+// rosterUser looks up a user by email if the roster gives one, else by name.
+func (t *Team) rosterUser(name string) (*model.User, error) {
+	if strings.Contains(name, "@") && !strings.HasPrefix(name, "@") {
+		user, appErr := t.c.API.GetUserByEmail(name)
+		return user, apiError("find user by email", name, appErr)
+	}
+
+	name = strings.TrimPrefix(name, "@")
+	user, appErr := t.c.API.GetUserByUsername(name)
+	return user, apiError("find user", name, appErr)
+}
+
+// hasMember tells whether the user is a member of the team.
+func (t *Team) hasMember(user *model.User) (bool, error) {
+	member, appErr := t.c.API.GetTeamMember(t.Id, user.Id)
+	err := apiError("get team member", user.Username, appErr)
+	if KindOf(err) == KindNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return member.DeleteAt == 0, nil
+}
+
+// rosterChange compares the listed users with the members of the channel.
+func (t *Team) rosterChange(channel *model.Channel, listed map[string]*model.User) (*RosterChange, error) {
+	change := &RosterChange{Channel: channel}
+	members := make(map[string]bool)
+
+	page := 0
+	perPage := 100
+	for {
+		channelMembers, appErr := t.c.API.GetChannelMembers(channel.Id, page, perPage)
+		if appErr != nil {
+			return nil, apiError("list members of channel", channel.Name, appErr)
+		}
+		if len(channelMembers) == 0 {
+			break
+		}
+
+		for _, member := range channelMembers {
+			members[member.UserId] = true
+			if listed[member.UserId] != nil || channel.Name == model.DefaultChannelName {
+				continue
+			}
+			user, appErr := t.c.API.GetUser(member.UserId)
+			if appErr != nil {
+				return nil, apiError("get user", member.UserId, appErr)
+			}
+			if !user.IsBot {
+				change.Remove = append(change.Remove, user)
+			}
+		}
+		page++
+	}
+
+	for _, user := range listed {
+		if !members[user.Id] {
+			change.Add = append(change.Add, user)
+		}
+	}
+	sortUsers(change.Add)
+	sortUsers(change.Remove)
+
+	return change, nil
+}
+
+// static
+
+// expandRosterRoles replaces the roles among the names by their channels.
+func expandRosterRoles(names []string) []string {
+	var channels []string
+	for _, name := range names {
+		if roleChannels, isRole := config.RosterRoles[name]; isRole {
+			channels = append(channels, roleChannels...)
+		} else {
+			channels = append(channels, strings.TrimPrefix(name, "~"))
+		}
+	}
+	return channels
+}
+
+func isRosterHeader(field string) bool {
+	switch strings.ToLower(field) {
+	case "username", "email", "user":
+		return true
+	}
+	return false
+}
+
+func sortUsers(users []*model.User) {
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+}
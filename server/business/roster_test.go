@@ -0,0 +1,115 @@
+package business
+
+import (
+	"github.com/mattermost/mattermost-server/v6/model"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseRoster(t *testing.T) {
+	entries, err := ParseRoster([]byte("username,channels\nskipper,monday-races;seven-bars\n\n@bosun, racing-crew, ~laser\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []RosterEntry{
+		{Line: 2, User: "skipper", Channels: []string{"monday-races", "seven-bars"}},
+		{Line: 3, User: "@bosun", Channels: []string{"racing-crew", "~laser"}},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("entries = %+v, want %+v", entries, expected)
+	}
+
+	if _, err := ParseRoster([]byte("skipper,\"laser\n")); KindOf(err) != KindInvalid {
+		t.Errorf("err = %v, want invalid", err)
+	}
+}
+
+func TestFindRoster(t *testing.T) {
+	f := newFixture(t)
+	channelID := f.channels["town-square"].Id
+	f.api.AddFilePost(channelID, f.admin.Id, "old.csv", []byte("old"))
+	f.api.AddFilePost(channelID, f.admin.Id, "photo.jpg", []byte("jpg"))
+	f.api.AddFilePost(channelID, f.addUser("skipper").Id, "other.csv", []byte("other"))
+
+	file, data, err := FindRoster(f.c, channelID, f.admin.Id)
+	if err != nil || file != "old.csv" || string(data) != "old" {
+		t.Errorf("roster = %s %q %v, want the CSV file of the admin", file, data, err)
+	}
+
+	if _, _, err := FindRoster(f.c, f.channels["laser"].Id, f.admin.Id); KindOf(err) != KindNotFound {
+		t.Errorf("err = %v, want not found", err)
+	}
+}
+
+func TestPlanAndApplyRoster(t *testing.T) {
+	f := newFixture(t)
+	skipper := f.addUser("skipper")
+	bosun := f.addUser("bosun")
+	mate := f.addUser("mate")
+	f.api.AddMember(f.channels["laser"].Id, mate.Id)
+	f.api.AddMember(f.channels["laser"].Id, f.c.BotID)
+
+	f.api.AddUser("guest", model.SystemUserRoleId)
+
+	entries, err := ParseRoster([]byte("skipper,laser;buzz;town-square\nbosun@example.com,laser;atlantis\nghost,laser\nguest,laser\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	team := WrapTeam(f.c, f.team)
+	plan, err := team.PlanRoster("crew.csv", entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Changes) != 2 {
+		t.Fatalf("changes = %+v, want laser and buzz", plan.Changes)
+	}
+	laser := plan.Changes[0]
+	if laser.Channel.Name != "laser" || !reflect.DeepEqual(usernames(laser.Add), []string{"bosun", "skipper"}) || !reflect.DeepEqual(usernames(laser.Remove), []string{"mate"}) {
+		t.Errorf("laser = add %v remove %v, want add bosun and skipper, remove mate", usernames(laser.Add), usernames(laser.Remove))
+	}
+	if buzz := plan.Changes[1]; buzz.Channel.Name != "buzz" || !reflect.DeepEqual(usernames(buzz.Add), []string{"skipper"}) {
+		t.Errorf("buzz = add %v, want skipper", usernames(buzz.Add))
+	}
+	if !reflect.DeepEqual(plan.OutsideTeam, []string{"guest (line 4)"}) {
+		t.Errorf("outside of the team = %v, want guest", plan.OutsideTeam)
+	}
+	if !reflect.DeepEqual(plan.UnknownUsers, []string{"ghost (line 3)"}) || !reflect.DeepEqual(plan.UnknownChannels, []string{"atlantis"}) {
+		t.Errorf("unknown = %v %v, want ghost and atlantis", plan.UnknownUsers, plan.UnknownChannels)
+	}
+
+	// Members not listed are only removed when asked to
+	if err := team.ApplyRoster(plan, false); err != nil {
+		t.Fatal(err)
+	}
+	if !f.api.IsMember(f.channels["laser"].Id, mate.Id) {
+		t.Errorf("mate was removed from laser without asking")
+	}
+	for _, user := range []*model.User{skipper, bosun} {
+		if !f.api.IsMember(f.channels["laser"].Id, user.Id) {
+			t.Errorf("%s was not added to laser", user.Username)
+		}
+	}
+	if !f.api.IsMember(f.channels["buzz"].Id, skipper.Id) {
+		t.Errorf("skipper was not added to buzz")
+	}
+	if posts := f.api.Posts(f.channels["buzz"].Id); len(posts) != 1 || posts[0].UserId != f.c.BotID {
+		t.Errorf("membership messages %v, want one by the bot", posts)
+	}
+
+	f.api.Fail("DeleteChannelMember", http.StatusForbidden)
+	err = team.ApplyRoster(plan, true)
+	if items := ItemErrors(err); len(items) != 1 || items[0].Item != "mate from laser" {
+		t.Errorf("err = %v, want removing mate to fail", err)
+	}
+}
+
+func usernames(users []*model.User) []string {
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	return names
+}
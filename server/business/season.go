@@ -0,0 +1,230 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/utils"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"time"
+)
+
+// now returns the time the windows of the structure are checked against;
+// tests replace it to move through the seasons.
+var now = time.Now
+
+// members
+
+// ArchiveEndedChannels takes the channels whose window ended out of use. With
+// config.ArchiveEndedChannels they are archived, otherwise they are moved to
+// the archive category of every member of the team except bots. It returns
+// the archived channels or the members whose sidebar changed.
+func (t *Team) ArchiveEndedChannels() ([]string, error) {
+	if config.ArchiveEndedChannels {
+		return t.archiveChannels(config.EndedChannels(now()))
+	}
+
+	var moved []string
+	failed := failures{op: "move ended channels"}
+
+	page := 0
+	perPage := 100
+	for {
+		users, appErr := t.c.API.GetUsersInTeam(t.Team.Id, page, perPage)
+		if appErr != nil {
+			return nil, apiError("list members of team", t.Name, appErr)
+		}
+
+		if len(users) == 0 {
+			break
+		}
+
+		for _, user := range users {
+			if user.IsBot {
+				continue
+			}
+			s, err := NewSideBar(newUserWithIndex(t.c, user, t.channels))
+			if err != nil {
+				failed.add(user.Username, err)
+				continue
+			}
+			channels, err := s.MoveEndedChannels()
+			if err != nil {
+				failed.add(user.Username, err)
+			}
+			if len(channels) > 0 {
+				moved = append(moved, user.Username)
+			}
+		}
+
+		page++
+	}
+
+	return moved, failed.err()
+}
+
+// SeasonResult lists what a season reconcile changed: the channels each member
+// joined and what ArchiveEndedChannels returned.
+type SeasonResult struct {
+	Joined   map[string][]string // by username
+	Archived []string
+}
+
+// ReconcileSeason brings every member of the team in line with the windows of
+// the structure: members join the channels that became active, which are put
+// in their categories, and ended channels are archived as ArchiveEndedChannels
+// does. Members that could not be reconciled are reported as a partial
+// failure.
+func (t *Team) ReconcileSeason() (*SeasonResult, error) {
+	result := &SeasonResult{Joined: make(map[string][]string)}
+	failed := failures{op: "reconcile season"}
+
+	page := 0
+	perPage := 100
+	for {
+		users, appErr := t.c.API.GetUsersInTeam(t.Team.Id, page, perPage)
+		if appErr != nil {
+			return nil, apiError("list members of team", t.Name, appErr)
+		}
+
+		if len(users) == 0 {
+			break
+		}
+
+		for _, user := range users {
+			if user.IsBot {
+				continue
+			}
+			joined, err := t.joinActiveChannels(newUserWithIndex(t.c, user, t.channels))
+			if err != nil {
+				failed.add(user.Username, err)
+			}
+			if len(joined) > 0 {
+				result.Joined[user.Username] = joined
+			}
+		}
+
+		page++
+	}
+
+	archived, err := t.ArchiveEndedChannels()
+	failed.merge(err)
+	result.Archived = archived
+
+	return result, failed.err()
+}
+
+// MoveEndedChannels moves the ended channels the user is a member of to the
+// archive category and reorders the sidebar. It returns the channels moved;
+// channels already in the archive category are not moved again.
+func (s *SideBar) MoveEndedChannels() ([]string, error) {
+	ended, err := s.u.endedChannels()
+	if err != nil || len(ended) == 0 {
+		return nil, err
+	}
+
+	if err := s.fetch(); err != nil {
+		return nil, err
+	}
+	var archived []string
+	for _, category := range s.categories.Categories {
+		if category.DisplayName == config.ArchiveCategory {
+			archived = append(archived, category.Channels...)
+		}
+	}
+
+	var moved []string
+	for _, entry := range ended {
+		channel, err := s.u.channels.Resolve(entry)
+		if err == nil && !utils.Contains(archived, channel.Id) {
+			moved = append(moved, entry.String())
+		}
+	}
+	if len(moved) == 0 {
+		return nil, nil
+	}
+
+	archive := config.Category{Name: config.ArchiveCategory, Policy: config.ArchivePolicy, Channels: ended}
+	if err := s.createMissingSidebarCategories([]config.Category{archive}); err != nil {
+		return nil, err
+	}
+	if _, err := s.ReorderSidebarCategories(); err != nil {
+		return nil, err
+	}
+
+	return moved, nil
+}
+
+// private
+
+// endedChannels returns the ended channels of the structure the user is
+// still a member of.
+func (u *User) endedChannels() ([]config.Channel, error) {
+	var ended []config.Channel
+
+	for _, entry := range config.EndedChannels(now()) {
+		channel, err := u.channels.Resolve(entry)
+		if err != nil {
+			continue
+		}
+		isMember, err := u.channels.IsMember(u.Id, channel.Id)
+		if err != nil {
+			return nil, err
+		}
+		if isMember {
+			ended = append(ended, entry)
+		}
+	}
+	return ended, nil
+}
+
+// joinActiveChannels adds the user to the active channels of their structure
+// and puts the channels joined in their categories.
+func (t *Team) joinActiveChannels(u *User) ([]string, error) {
+	structure, err := u.Structure()
+	if err != nil {
+		return nil, err
+	}
+
+	join, err := u.JoinMissingChannels(structure, nil)
+	if err != nil || len(join.Joined) == 0 {
+		return join.Joined, err
+	}
+
+	s, err := NewSideBar(u)
+	if err != nil {
+		return join.Joined, err
+	}
+	if err := s.createMissingSidebarCategories(structure); err != nil {
+		return join.Joined, err
+	}
+	_, err = s.assignChannelsToCategories(structure)
+	return join.Joined, err
+}
+
+func (t *Team) archiveChannels(entries []config.Channel) ([]string, error) {
+	var archived []string
+	failed := failures{op: "archive ended channels"}
+
+	for _, entry := range entries {
+		channel, err := t.channels.Resolve(entry)
+		if KindOf(err) == KindNotFound || (err == nil && channel.DeleteAt != 0) {
+			// Archived before
+			continue
+		}
+		if err != nil {
+			failed.add(entry.String(), err)
+			continue
+		}
+		if channel.Name == model.DefaultChannelName {
+			failed.add(entry.String(), NewError(KindInvalid, "archive channel", entry.String(), nil))
+			continue
+		}
+
+		if appErr := t.c.API.DeleteChannel(channel.Id); appErr != nil {
+			failed.add(entry.String(), apiError("archive channel", entry.String(), appErr))
+			continue
+		}
+		archived = append(archived, entry.String())
+	}
+
+	return archived, failed.err()
+}
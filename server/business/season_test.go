@@ -0,0 +1,182 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSeasonalChannels(t *testing.T) {
+	structure := config.Structure
+	defer func() {
+		config.Structure = structure
+		now = time.Now
+	}()
+
+	season := config.Window{
+		From:  time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC),
+	}
+	config.Structure = []config.Category{
+		{Name: "Club Life", Channels: []config.Channel{
+			{DisplayName: "Town Square", Name: "town-square"},
+		}},
+		{Name: "Racing", Channels: []config.Channel{
+			{DisplayName: "Monday Races", Name: "monday-races"},
+			{DisplayName: "Kaag Cup", Name: "kaag-cup", Active: season},
+		}},
+	}
+
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	kaagCup := f.channels["kaag-cup"].Id
+
+	// Before the season the cup is not joined
+	now = func() time.Time { return season.From.Add(-time.Hour) }
+	f.onboard(t, user)
+	if f.api.IsMember(kaagCup, user.Id) {
+		t.Fatalf("joined the cup before its season")
+	}
+
+	// At season start it is
+	now = func() time.Time { return season.From }
+	f.onboard(t, user)
+	if channels := f.category(user, "Racing").Channels; !reflect.DeepEqual(channels, []string{f.channels["monday-races"].Id, kaagCup}) {
+		t.Fatalf("channels of Racing in season = %v", channels)
+	}
+
+	// After the season it moves to the archive
+	now = func() time.Time { return season.Until }
+	moved, err := f.sideBar(t, user).MoveEndedChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(moved, []string{"Kaag Cup"}) {
+		t.Errorf("moved = %v, want the cup", moved)
+	}
+	if archive := f.category(user, config.ArchiveCategory); archive == nil || !reflect.DeepEqual(archive.Channels, []string{kaagCup}) {
+		t.Fatalf("archive category = %+v, want the cup", archive)
+	}
+
+	// and stays there when reordering and checking
+	if _, err := f.sideBar(t, user).ReorderSidebarCategories(); err != nil {
+		t.Fatal(err)
+	}
+	if channels := f.category(user, config.ArchiveCategory).Channels; !reflect.DeepEqual(channels, []string{kaagCup}) {
+		t.Errorf("archive after reordering = %v", channels)
+	}
+	report, err := f.sideBar(t, user).CheckChannelStructure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Compliant() {
+		t.Errorf("report after the season = %+v, want compliant", report)
+	}
+}
+
+func TestArchiveEndedChannels(t *testing.T) {
+	structure := config.Structure
+	defer func() {
+		config.Structure = structure
+		config.ArchiveEndedChannels = false
+		now = time.Now
+	}()
+
+	ended := config.Window{Until: time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)}
+	config.Structure = []config.Category{
+		{Name: "Racing", Channels: []config.Channel{
+			{DisplayName: "Monday Races", Name: "monday-races"},
+			{DisplayName: "Kaag Cup", Name: "kaag-cup", Active: ended},
+		}},
+	}
+	config.ArchiveEndedChannels = true
+	now = func() time.Time { return ended.Until }
+
+	f := newFixture(t)
+	team := WrapTeam(f.c, f.team)
+
+	archived, err := team.ArchiveEndedChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(archived, []string{"Kaag Cup"}) {
+		t.Errorf("archived = %v, want the cup", archived)
+	}
+	if f.channels["kaag-cup"].DeleteAt == 0 || f.channels["monday-races"].DeleteAt != 0 {
+		t.Errorf("archived the wrong channels")
+	}
+
+	// Archiving again finds nothing left to archive
+	if archived, err := WrapTeam(f.c, f.team).ArchiveEndedChannels(); err != nil || len(archived) != 0 {
+		t.Errorf("second run archived %v, %v", archived, err)
+	}
+	if diagnostics := team.validateChannels(config.Structure); len(diagnostics) != 0 {
+		t.Errorf("diagnostics for the archived channel: %v", diagnostics)
+	}
+}
+
+func TestReconcileSeason(t *testing.T) {
+	structure := config.Structure
+	defer func() {
+		config.Structure = structure
+		now = time.Now
+	}()
+
+	season := config.Window{
+		From:  time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC),
+	}
+	config.Structure = []config.Category{
+		{Name: "Club Life", Channels: []config.Channel{
+			{DisplayName: "Town Square", Name: "town-square"},
+		}},
+		{Name: "Racing", Channels: []config.Channel{
+			{DisplayName: "Monday Races", Name: "monday-races"},
+			{DisplayName: "Kaag Cup", Name: "kaag-cup", Active: season},
+		}},
+	}
+
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	kaagCup := f.channels["kaag-cup"].Id
+	f.api.AddTeamMember(f.team.Id, f.c.BotID)
+	f.api.AddMember(kaagCup, f.c.BotID)
+	now = func() time.Time { return season.From.Add(-time.Hour) }
+	f.onboard(t, user)
+
+	// At season start every member joins the cup
+	now = func() time.Time { return season.From }
+	result, err := WrapTeam(f.c, f.team).ReconcileSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Joined["skipper"], []string{"Kaag Cup"}) {
+		t.Errorf("joined = %v, want the cup for skipper", result.Joined)
+	}
+	if channels := f.category(user, "Racing").Channels; !reflect.DeepEqual(channels, []string{f.channels["monday-races"].Id, kaagCup}) {
+		t.Errorf("channels of Racing in season = %v", channels)
+	}
+
+	// After the season it moves to the archive
+	now = func() time.Time { return season.Until }
+	result, err = WrapTeam(f.c, f.team).ReconcileSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Joined) != 0 || !reflect.DeepEqual(result.Archived, []string{"admin", "skipper"}) {
+		t.Errorf("result = %+v, want the cup moved to the archive of its members", result)
+	}
+	if archive := f.category(user, config.ArchiveCategory); archive == nil || !reflect.DeepEqual(archive.Channels, []string{kaagCup}) {
+		t.Errorf("archive category = %+v, want the cup", archive)
+	}
+
+	// Reconciling again changes nothing
+	result, err = WrapTeam(f.c, f.team).ReconcileSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Joined) != 0 || len(result.Archived) != 0 {
+		t.Errorf("second run = %+v, want nothing changed", result)
+	}
+}
@@ -0,0 +1,247 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/utils"
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+type SidebarOpKind string
+
+const (
+	OpCreateCategory SidebarOpKind = "create_category"
+	OpMoveChannel    SidebarOpKind = "move_channel"
+	OpSetSorting     SidebarOpKind = "set_sorting"
+	OpDeleteCategory SidebarOpKind = "delete_category"
+	OpSetSortOrder   SidebarOpKind = "set_sort_order"
+)
+
+// SidebarOp is one change of a sidebar. Categories are named by Category and
+// From and identified by CategoryID and FromID, which are empty for a
+// category the operations create. A move puts Channel, an ID, from the
+// category From at position Index of Category; From is empty for channels in
+// no category. A new sort order puts Category at position Index of the
+// sidebar. Sorting is set by OpSetSorting and by OpCreateCategory.
+type SidebarOp struct {
+	Kind       SidebarOpKind
+	Category   string
+	CategoryID string
+	Channel    string
+	From       string
+	FromID     string
+	Index      int
+	Sorting    model.SidebarCategorySorting
+}
+
+// DesiredCategory is a category of a desired sidebar layout with the IDs of
+// its channels in order. ID is the ID of an existing category, empty for a
+// category to create. An empty Sorting keeps the sorting of the category.
+type DesiredCategory struct {
+	ID       string
+	Name     string
+	Channels []string
+	Sorting  model.SidebarCategorySorting
+}
+
+// DiffSidebar returns the operations turning the current sidebar into the
+// desired layout, in the order they are to be applied: categories are
+// created, channels moved, sorting set, categories deleted and the categories
+// ordered last.
+//
+// Categories are matched by ID, so categories with the same name stay apart.
+// Custom categories missing from the layout are deleted. System categories
+// missing from it follow the listed categories, and channels missing from it
+// stay where they are, after the listed ones. Channels and categories already
+// in the desired relative order are not moved.
+func DiffSidebar(current *model.OrderedSidebarCategories, desired []DesiredCategory) []SidebarOp {
+	var ops []SidebarOp
+
+	layout := normalizeLayout(current, desired)
+	existing := categoriesByID(current)
+
+	location := make(map[string]*model.SidebarCategoryWithChannels)
+	for _, category := range current.Categories {
+		for _, channelID := range category.Channels {
+			location[channelID] = category
+		}
+	}
+
+	for _, category := range layout {
+		if category.ID == "" {
+			ops = append(ops, SidebarOp{Kind: OpCreateCategory, Category: category.Name, Sorting: category.Sorting})
+		}
+	}
+
+	for _, category := range layout {
+		var currentChannels []string
+		if category.ID != "" {
+			currentChannels = existing[category.ID].Channels
+		}
+
+		staying := inOrder(currentChannels, category.Channels)
+		for index, channelID := range category.Channels {
+			if staying[channelID] {
+				continue
+			}
+			op := SidebarOp{Kind: OpMoveChannel, Category: category.Name, CategoryID: category.ID, Channel: channelID, Index: index}
+			if from := location[channelID]; from != nil {
+				op.From, op.FromID = from.DisplayName, from.Id
+			}
+			ops = append(ops, op)
+		}
+	}
+
+	for _, category := range layout {
+		if category.ID != "" && category.Sorting != "" && category.Sorting != existing[category.ID].Sorting {
+			ops = append(ops, SidebarOp{Kind: OpSetSorting, Category: category.Name, CategoryID: category.ID, Sorting: category.Sorting})
+		}
+	}
+
+	var layoutIDs, layoutKeys, remainingKeys []string
+	for _, category := range layout {
+		layoutKeys = append(layoutKeys, category.key())
+		if category.ID != "" {
+			layoutIDs = append(layoutIDs, category.ID)
+		}
+	}
+	for _, category := range current.Categories {
+		if utils.Contains(layoutIDs, category.Id) {
+			remainingKeys = append(remainingKeys, category.Id)
+		} else {
+			ops = append(ops, SidebarOp{Kind: OpDeleteCategory, Category: category.DisplayName, CategoryID: category.Id})
+		}
+	}
+
+	staying := inOrder(remainingKeys, layoutKeys)
+	for index, category := range layout {
+		if !staying[category.key()] {
+			ops = append(ops, SidebarOp{Kind: OpSetSortOrder, Category: category.Name, CategoryID: category.ID, Index: index})
+		}
+	}
+
+	return ops
+}
+
+// private
+
+// key identifies the category in a layout: by ID if it exists, else by name.
+func (d DesiredCategory) key() string {
+	if d.ID != "" {
+		return d.ID
+	}
+	return d.Name
+}
+
+// normalizeLayout completes the desired layout into the sidebar that results
+// from it: a channel stays in the first category listing it, IDs of missing
+// categories are dropped, system categories missing from the layout are
+// appended, channels missing from it are appended to their category, or to
+// "Channels" if their category is deleted.
+func normalizeLayout(current *model.OrderedSidebarCategories, desired []DesiredCategory) []DesiredCategory {
+	var layout []DesiredCategory
+	placed := make(map[string]bool)
+	index := make(map[string]int)
+	existing := categoriesByID(current)
+
+	for _, category := range desired {
+		normalized := DesiredCategory{ID: category.ID, Name: category.Name, Sorting: category.Sorting}
+		if existing[normalized.ID] == nil {
+			normalized.ID = ""
+		}
+		if _, listed := index[normalized.key()]; listed {
+			continue
+		}
+		for _, channelID := range category.Channels {
+			if !placed[channelID] {
+				placed[channelID] = true
+				normalized.Channels = append(normalized.Channels, channelID)
+			}
+		}
+		index[normalized.key()] = len(layout)
+		layout = append(layout, normalized)
+	}
+
+	channelsCategory := ""
+	for _, category := range current.Categories {
+		if category.Type == model.SidebarCategoryChannels {
+			channelsCategory = category.Id
+		}
+		if _, listed := index[category.Id]; !listed && category.Type != model.SidebarCategoryCustom {
+			index[category.Id] = len(layout)
+			layout = append(layout, DesiredCategory{ID: category.Id, Name: category.DisplayName})
+		}
+	}
+
+	// Kept categories keep their channels, then the channels of deleted ones
+	// follow in "Channels"
+	for _, deleted := range []bool{false, true} {
+		for _, category := range current.Categories {
+			target, kept := index[category.Id]
+			if kept == deleted {
+				continue
+			}
+			if deleted {
+				if target, kept = index[channelsCategory]; !kept {
+					continue
+				}
+			}
+			for _, channelID := range category.Channels {
+				if !placed[channelID] {
+					placed[channelID] = true
+					layout[target].Channels = append(layout[target].Channels, channelID)
+				}
+			}
+		}
+	}
+
+	return layout
+}
+
+// inOrder returns the items of the desired list that are already in the
+// current list in the desired relative order, as many as possible: a longest
+// common subsequence of both lists.
+func inOrder(current []string, desired []string) map[string]bool {
+	position := make(map[string]int)
+	for i, item := range current {
+		position[item] = i
+	}
+
+	// Positions in the current list, in the desired order
+	var items []string
+	var positions []int
+	for _, item := range desired {
+		if i, exists := position[item]; exists {
+			items = append(items, item)
+			positions = append(positions, i)
+		}
+	}
+
+	// Longest increasing subsequence of the positions
+	length := make([]int, len(positions))
+	previous := make([]int, len(positions))
+	best := -1
+	for i := range positions {
+		length[i], previous[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if positions[j] < positions[i] && length[j]+1 > length[i] {
+				length[i], previous[i] = length[j]+1, j
+			}
+		}
+		if best < 0 || length[i] > length[best] {
+			best = i
+		}
+	}
+
+	staying := make(map[string]bool)
+	for i := best; i >= 0; i = previous[i] {
+		staying[items[i]] = true
+	}
+	return staying
+}
+
+func categoriesByID(sidebar *model.OrderedSidebarCategories) map[string]*model.SidebarCategoryWithChannels {
+	categories := make(map[string]*model.SidebarCategoryWithChannels)
+	for _, category := range sidebar.Categories {
+		categories[category.Id] = category
+	}
+	return categories
+}
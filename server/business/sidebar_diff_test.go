@@ -0,0 +1,244 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/utils"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func sidebarOf(categories ...*model.SidebarCategoryWithChannels) *model.OrderedSidebarCategories {
+	return &model.OrderedSidebarCategories{Categories: categories}
+}
+
+func sidebarCategory(name string, categoryType model.SidebarCategoryType, channels ...string) *model.SidebarCategoryWithChannels {
+	return &model.SidebarCategoryWithChannels{
+		SidebarCategory: model.SidebarCategory{Id: name, DisplayName: name, Type: categoryType, Sorting: model.SidebarCategorySortManual},
+		Channels:        channels,
+	}
+}
+
+func TestDiffSidebar(t *testing.T) {
+	favorites := sidebarCategory("Favorites", model.SidebarCategoryFavorites, "f")
+	channels := sidebarCategory("Channels", model.SidebarCategoryChannels, "x", "y")
+	racing := sidebarCategory("Racing", model.SidebarCategoryCustom, "a", "b", "c", "d")
+	old := sidebarCategory("Old", model.SidebarCategoryCustom, "o")
+	current := sidebarOf(favorites, racing, old, channels)
+
+	tests := []struct {
+		name    string
+		desired []DesiredCategory
+		ops     []SidebarOp
+	}{
+		{"in line", []DesiredCategory{
+			{ID: "Favorites", Name: "Favorites", Channels: []string{"f"}},
+			{ID: "Racing", Name: "Racing", Channels: []string{"a", "b", "c", "d"}},
+			{ID: "Old", Name: "Old", Channels: []string{"o"}},
+			{ID: "Channels", Name: "Channels", Channels: []string{"x", "y"}},
+		}, nil},
+		{"minimal channel moves", []DesiredCategory{
+			{ID: "Favorites", Name: "Favorites"},
+			{ID: "Racing", Name: "Racing", Channels: []string{"b", "c", "d", "a"}},
+			{ID: "Old", Name: "Old"},
+		}, []SidebarOp{
+			{Kind: OpMoveChannel, Category: "Racing", CategoryID: "Racing", Channel: "a", From: "Racing", FromID: "Racing", Index: 3},
+		}},
+		{"system categories follow the listed ones", []DesiredCategory{
+			{ID: "Racing", Name: "Racing", Channels: []string{"a", "b", "c", "d"}},
+			{ID: "Old", Name: "Old", Channels: []string{"o"}},
+		}, []SidebarOp{
+			{Kind: OpSetSortOrder, Category: "Favorites", CategoryID: "Favorites", Index: 2},
+		}},
+		{"channels between categories", []DesiredCategory{
+			{ID: "Favorites", Name: "Favorites"},
+			{ID: "Racing", Name: "Racing", Channels: []string{"a", "x", "b", "c", "d"}},
+			{ID: "Old", Name: "Old"},
+		}, []SidebarOp{
+			{Kind: OpMoveChannel, Category: "Racing", CategoryID: "Racing", Channel: "x", From: "Channels", FromID: "Channels", Index: 1},
+		}},
+		{"create, sort, delete and order", []DesiredCategory{
+			{ID: "Favorites", Name: "Favorites"},
+			{Name: "Cruising", Channels: []string{"y"}, Sorting: model.SidebarCategorySortAlphabetical},
+			{ID: "Racing", Name: "Racing", Channels: []string{"a", "b", "c", "d"}, Sorting: model.SidebarCategorySortRecent},
+		}, []SidebarOp{
+			{Kind: OpCreateCategory, Category: "Cruising", Sorting: model.SidebarCategorySortAlphabetical},
+			{Kind: OpMoveChannel, Category: "Cruising", Channel: "y", From: "Channels", FromID: "Channels", Index: 0},
+			// The channels of the deleted category go back to Channels
+			{Kind: OpMoveChannel, Category: "Channels", CategoryID: "Channels", Channel: "o", From: "Old", FromID: "Old", Index: 1},
+			{Kind: OpSetSorting, Category: "Racing", CategoryID: "Racing", Sorting: model.SidebarCategorySortRecent},
+			{Kind: OpDeleteCategory, Category: "Old", CategoryID: "Old"},
+			{Kind: OpSetSortOrder, Category: "Cruising", Index: 1},
+		}},
+		{"categories matched by ID", []DesiredCategory{
+			{ID: "Favorites", Name: "Favorites"},
+			{Name: "Racing", Channels: []string{"a", "b", "c", "d"}},
+			{ID: "Old", Name: "Old"},
+		}, []SidebarOp{
+			{Kind: OpCreateCategory, Category: "Racing"},
+			{Kind: OpMoveChannel, Category: "Racing", Channel: "a", From: "Racing", FromID: "Racing", Index: 0},
+			{Kind: OpMoveChannel, Category: "Racing", Channel: "b", From: "Racing", FromID: "Racing", Index: 1},
+			{Kind: OpMoveChannel, Category: "Racing", Channel: "c", From: "Racing", FromID: "Racing", Index: 2},
+			{Kind: OpMoveChannel, Category: "Racing", Channel: "d", From: "Racing", FromID: "Racing", Index: 3},
+			{Kind: OpDeleteCategory, Category: "Racing", CategoryID: "Racing"},
+			{Kind: OpSetSortOrder, Category: "Racing", Index: 1},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ops := DiffSidebar(current, test.desired); !reflect.DeepEqual(ops, test.ops) {
+				t.Errorf("ops = %+v\nwant %+v", ops, test.ops)
+			}
+		})
+	}
+}
+
+func TestApplySidebarPlan(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.onboard(t, user)
+
+	// Mess up the sidebar: a managed channel in Channels, a stale category
+	s := f.sideBar(t, user)
+	laser := f.channels["laser"].Id
+	channels := f.category(user, "Channels")
+	channels.Channels = append(channels.Channels, laser)
+	if _, appErr := f.api.UpdateChannelSidebarCategories(user.Id, f.team.Id, []*model.SidebarCategoryWithChannels{channels}); appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	plan, err := s.PlanSidebar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Ops) == 0 {
+		t.Fatal("no operations planned")
+	}
+	f.api.ResetCalls()
+	if err := s.ApplySidebarPlan(plan); err != nil {
+		t.Fatal(err)
+	}
+	if calls := f.api.Calls("UpdateChannelSidebarCategories"); calls != 1 {
+		t.Errorf("%d batch updates, want 1", calls)
+	}
+
+	if !reflect.DeepEqual(f.category(user, "Racing").Channels, f.configuredChannelIDs("Racing")) {
+		t.Errorf("channels of Racing = %v, want %v", f.category(user, "Racing").Channels, f.configuredChannelIDs("Racing"))
+	}
+	expected := append(append([]string{"Favorites"}, config.CategoryOrder...), "Channels", "Direct Messages")
+	if names := f.categoryNames(user); !reflect.DeepEqual(names, expected) {
+		t.Errorf("categories = %v, want %v", names, expected)
+	}
+
+	// Applying the plan brings the sidebar in line
+	if plan, err := s.PlanSidebar(); err != nil || len(plan.Ops) != 0 {
+		t.Errorf("planned %+v, %v after applying, want nothing", plan.Ops, err)
+	}
+}
+
+func TestApplySidebarPlanKeepsChannelsOfFailedCategory(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("bosun")
+	townSquare := f.channels["town-square"].Id
+
+	s := f.sideBar(t, user)
+	plan, err := s.PlanSidebar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Ops[0].Kind != OpCreateCategory || plan.Ops[0].Category != "Club Life" {
+		t.Fatalf("first operation = %+v, want creating Club Life", plan.Ops[0])
+	}
+
+	f.api.Fail("CreateChannelSidebarCategory", http.StatusInternalServerError)
+	if err := s.ApplySidebarPlan(plan); KindOf(err) != KindPartial {
+		t.Fatalf("err = %v, want a partial failure", err)
+	}
+
+	if f.category(user, "Club Life") != nil {
+		t.Fatalf("Club Life was created")
+	}
+	if channels := f.category(user, "Channels").Channels; !utils.Contains(channels, townSquare) {
+		t.Errorf("channels of Channels = %v, want Town Square kept", channels)
+	}
+}
+
+func TestApplySidebarPlanKeepsCategoriesWithTheSameName(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.onboard(t, user)
+
+	var mine []*model.SidebarCategoryWithChannels
+	for _, name := range []string{"regatta-photos", "boat-sale"} {
+		channel := f.api.AddChannel(f.team.Id, name, name, model.ChannelTypeOpen)
+		f.api.AddMember(channel.Id, user.Id)
+		category := createCategory(f, user, "Mine")
+		category.Channels = []string{channel.Id}
+		updateCategory(f, user, category)
+		mine = append(mine, category)
+	}
+
+	s := f.sideBar(t, user)
+	plan, err := s.PlanSidebar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApplySidebarPlan(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, category := range mine {
+		found := false
+		for _, current := range f.api.Categories(user.Id, f.team.Id) {
+			if current.Id == category.Id {
+				found = reflect.DeepEqual(current.Channels, category.Channels)
+			}
+		}
+		if !found {
+			t.Errorf("category Mine %s lost or changed", category.Id)
+		}
+	}
+}
+
+func TestApplySidebarPlanKeepsManagedCategoryLeftByUser(t *testing.T) {
+	structure := config.Structure
+	defer func() { config.Structure = structure }()
+
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.onboard(t, user)
+
+	extra := f.api.AddChannel(f.team.Id, "regatta-photos", "Regatta Photos", model.ChannelTypeOpen)
+	f.api.AddMember(extra.Id, user.Id)
+	fleet := f.category(user, "Fleet")
+	fleet.Channels = append(fleet.Channels, extra.Id)
+	updateCategory(f, user, fleet)
+
+	// The fleet is no longer meant for the user
+	config.Structure = append([]config.Category{}, structure...)
+	for i := range config.Structure {
+		if config.Structure[i].Name == "Fleet" {
+			config.Structure[i].Audience = config.Audience{Groups: []string{"boat-owners"}}
+		}
+	}
+
+	s := f.sideBar(t, user)
+	plan, err := s.PlanSidebar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range plan.Ops {
+		if op.Kind == OpDeleteCategory {
+			t.Errorf("planned to delete %s", op.Category)
+		}
+	}
+	if err := s.ApplySidebarPlan(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	if category := f.category(user, "Fleet"); category == nil || !utils.Contains(category.Channels, extra.Id) {
+		t.Errorf("Fleet = %+v, want it kept with the channel of the user", category)
+	}
+}
@@ -0,0 +1,266 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/utils"
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+// SidebarPlan is the sidebar layout meant for a user and the operations that
+// lead to it. ChannelNames maps channel IDs to display names for rendering.
+type SidebarPlan struct {
+	Layout       []DesiredCategory
+	Ops          []SidebarOp
+	ChannelNames map[string]string
+}
+
+// members
+
+// PlanSidebar compares the sidebar with the structure meant for the user and
+// plans the operations to bring it in line, without changing anything.
+func (s *SideBar) PlanSidebar() (*SidebarPlan, error) {
+	if err := s.fetch(); err != nil {
+		return nil, err
+	}
+
+	desired, names, err := s.desiredLayout()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SidebarPlan{
+		Layout:       normalizeLayout(s.categories, desired),
+		Ops:          DiffSidebar(s.categories, desired),
+		ChannelNames: names,
+	}, nil
+}
+
+// ApplySidebarPlan applies the operations of the plan: it creates the new
+// categories, updates the changed ones in a single batch, deletes the dropped
+// ones and sets the order of the categories.
+func (s *SideBar) ApplySidebarPlan(plan *SidebarPlan) error {
+	failed := failures{op: "apply sidebar plan of " + s.User.Username}
+
+	working := make(map[string]*model.SidebarCategoryWithChannels) // by ID
+	for _, category := range s.categories.Categories {
+		working[category.Id] = newSidebarCategory(category, append([]string{}, category.Channels...), int(category.SortOrder))
+	}
+	created := make(map[string]*model.SidebarCategoryWithChannels) // by name
+
+	// find returns the category an operation or the layout refers to, nil if
+	// its creation failed
+	find := func(id, name string) *model.SidebarCategoryWithChannels {
+		if id != "" {
+			return working[id]
+		}
+		return created[name]
+	}
+
+	changed := make(map[*model.SidebarCategoryWithChannels]bool)
+	var moves, deletions []SidebarOp
+	reorder := false
+
+	for _, op := range plan.Ops {
+		switch op.Kind {
+		case OpCreateCategory:
+			category, appErr := s.c.API.CreateChannelSidebarCategory(s.User.Id, s.c.Team.Id, &model.SidebarCategoryWithChannels{
+				SidebarCategory: model.SidebarCategory{
+					UserId:      s.User.Id,
+					TeamId:      s.c.Team.Id,
+					DisplayName: op.Category,
+					Type:        model.SidebarCategoryCustom,
+					Sorting:     op.Sorting,
+				},
+			})
+			if appErr != nil {
+				failed.add(op.Category, apiError("create sidebar category", op.Category, appErr))
+				continue
+			}
+			created[op.Category] = category
+		case OpMoveChannel:
+			moves = append(moves, op)
+		case OpSetSorting:
+			if category := find(op.CategoryID, op.Category); category != nil {
+				category.Sorting = op.Sorting
+				changed[category] = true
+			}
+		case OpDeleteCategory:
+			deletions = append(deletions, op)
+		case OpSetSortOrder:
+			reorder = true
+		}
+	}
+
+	// Channels leave their categories before they are inserted; the moves into
+	// a category come by ascending position. Channels stay where they are if
+	// the creation of their category failed.
+	for _, move := range moves {
+		if find(move.CategoryID, move.Category) == nil || move.FromID == "" {
+			continue
+		}
+		if from := working[move.FromID]; from != nil {
+			from.Channels = removeChannel(from.Channels, move.Channel)
+			changed[from] = true
+		}
+	}
+	for _, move := range moves {
+		category := find(move.CategoryID, move.Category)
+		if category == nil {
+			continue
+		}
+		category.Channels = insertAt(category.Channels, move.Index, move.Channel)
+		changed[category] = true
+	}
+
+	var updated []*model.SidebarCategoryWithChannels
+	for _, desired := range plan.Layout {
+		if category := find(desired.ID, desired.Name); category != nil && changed[category] {
+			updated = append(updated, category)
+		}
+	}
+	if len(updated) > 0 {
+		if _, appErr := s.c.API.UpdateChannelSidebarCategories(s.User.Id, s.c.Team.Id, updated); appErr != nil {
+			failed.add("", apiError("update sidebar categories of user", s.User.Username, appErr))
+		}
+	}
+
+	for _, op := range deletions {
+		if _, err := s.DeleteCategory(op.CategoryID); err != nil {
+			failed.add(op.Category, restError("delete sidebar category", op.Category, err))
+		}
+	}
+
+	if reorder {
+		var order []string
+		for _, desired := range plan.Layout {
+			if category := find(desired.ID, desired.Name); category != nil {
+				order = append(order, category.Id)
+			}
+		}
+		if _, err := s.SetCategoryOrder(order); err != nil {
+			failed.add("", restError("order sidebar categories of user", s.User.Username, err))
+		}
+	}
+
+	failed.merge(s.fetch())
+	return failed.err()
+}
+
+// private
+
+// desiredLayout builds the layout meant for the user, with the categories
+// placed as config.SidebarOrder says. Managed categories list their configured
+// channels the user is a member of first, then the channels the user added.
+// Categories are kept apart by ID; managed categories no longer in the
+// structure of the user are kept with their channels.
+func (s *SideBar) desiredLayout() ([]DesiredCategory, map[string]string, error) {
+	structure, err := s.u.Structure()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	memberChannels, err := s.u.channels.MemberChannels(s.User.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make(map[string]string)
+	for _, channel := range memberChannels {
+		names[channel.Id] = channel.DisplayName
+	}
+
+	existing := managedCategories(s.categories)
+	managedChannels := managedChannelCategories(s.u.channels, structure)
+	enforce := config.EnforceCategoryPolicies && !s.respectsCategorySettings()
+
+	var system, managed, userCreated []DesiredCategory
+	for _, category := range s.categories.Categories {
+		if category.Type != model.SidebarCategoryCustom {
+			system = append(system, DesiredCategory{ID: category.Id, Name: category.DisplayName, Channels: category.Channels})
+		}
+	}
+
+	var structureNames []string
+	for _, category := range structure {
+		structureNames = append(structureNames, category.Name)
+	}
+	for _, name := range structureNames {
+		desired := DesiredCategory{Name: name}
+		for _, channelID := range categoryChannelIDs(s.u.channels, structure, name) {
+			if _, isMember := names[channelID]; isMember {
+				desired.Channels = append(desired.Channels, channelID)
+			}
+		}
+		if current := existing[name]; current != nil {
+			desired.ID = current.Id
+			desired.Channels = appendUnique(desired.Channels, withoutManagedChannels(current.Channels, managedChannels, name)...)
+			if enforce {
+				desired.Sorting = config.Policy(name).Sorting
+			}
+		} else {
+			desired.Sorting = config.Policy(name).Sorting
+		}
+		managed = append(managed, desired)
+	}
+
+	for _, category := range s.categories.Categories {
+		isManaged := existing[category.DisplayName] == category
+		if category.Type != model.SidebarCategoryCustom || isManaged && utils.Contains(structureNames, category.DisplayName) {
+			continue
+		}
+		desired := DesiredCategory{
+			ID:       category.Id,
+			Name:     category.DisplayName,
+			Channels: withoutManagedChannels(category.Channels, managedChannels, ""),
+		}
+		if isManaged {
+			// A managed category no longer meant for the user
+			managed = append(managed, desired)
+		} else {
+			userCreated = append(userCreated, desired)
+		}
+	}
+
+	var keys, managedKeys, userKeys []string
+	categoryNames := make(map[string]string)
+	byKey := make(map[string]DesiredCategory)
+	for _, group := range [][]DesiredCategory{system, managed, userCreated} {
+		for _, category := range group {
+			keys = append(keys, category.key())
+			categoryNames[category.key()] = category.Name
+			byKey[category.key()] = category
+		}
+	}
+	for _, category := range managed {
+		managedKeys = append(managedKeys, category.key())
+	}
+	for _, category := range userCreated {
+		userKeys = append(userKeys, category.key())
+	}
+
+	var layout []DesiredCategory
+	for _, key := range sidebarOrder(keys, categoryNames, managedOrder(managedKeys, categoryNames), userKeys) {
+		layout = append(layout, byKey[key])
+	}
+
+	return layout, names, nil
+}
+
+func removeChannel(channelIDs []string, channelID string) []string {
+	var kept []string
+	for _, id := range channelIDs {
+		if id != channelID {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+func insertAt(channelIDs []string, index int, channelID string) []string {
+	if index > len(channelIDs) {
+		index = len(channelIDs)
+	}
+	channelIDs = append(channelIDs, "")
+	copy(channelIDs[index+1:], channelIDs[index:])
+	channelIDs[index] = channelID
+	return channelIDs
+}
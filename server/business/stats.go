@@ -0,0 +1,116 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"sort"
+)
+
+// ComplianceStats aggregates the structure reports of the members of a team.
+// The lists hold the StatsTop entries with the highest counts.
+type ComplianceStats struct {
+	Users             int         `json:"users"`
+	Compliant         int         `json:"compliant"`
+	Failed            []string    `json:"failed,omitempty"`
+	MissingChannels   []Count     `json:"missing_channels"`
+	MissingCategories []Count     `json:"missing_categories"`
+	Drift             []UserDrift `json:"drift"`
+}
+
+// Count is how many members miss a channel or category.
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// UserDrift is how many deviations from the structure a member has.
+type UserDrift struct {
+	Username string `json:"username"`
+	Drift    int    `json:"drift"`
+}
+
+// CompliantPercent is the share of the checked members who are compliant.
+func (s *ComplianceStats) CompliantPercent() float64 {
+	if s.Users == 0 {
+		return 100
+	}
+	return 100 * float64(s.Compliant) / float64(s.Users)
+}
+
+// Drift counts the deviations from the structure.
+func (r *StructureReport) Drift() int {
+	return len(r.MissingCategories) + len(r.MissingChannels) + len(r.WronglyCategorized)
+}
+
+// ComplianceStats checks every member of the team and aggregates the reports.
+// The errors of members whose check failed are listed in Failed.
+func (t *Team) ComplianceStats() (*ComplianceStats, error) {
+	reports, err := t.CheckUserChannelStructure()
+	if err != nil && KindOf(err) != KindPartial {
+		return nil, err
+	}
+
+	stats := SummarizeReports(reports, config.StatsTop)
+	for _, item := range ItemErrors(err) {
+		stats.Failed = append(stats.Failed, item.Error())
+	}
+	return stats, nil
+}
+
+// SummarizeReports aggregates structure reports, keeping the top entries of
+// each list.
+func SummarizeReports(reports []*StructureReport, top int) *ComplianceStats {
+	stats := &ComplianceStats{Users: len(reports)}
+	missingChannels := make(map[string]int)
+	missingCategories := make(map[string]int)
+
+	for _, report := range reports {
+		if report.Compliant() {
+			stats.Compliant++
+		}
+		for _, channel := range report.MissingChannels {
+			missingChannels[channel]++
+		}
+		for _, category := range report.MissingCategories {
+			missingCategories[category]++
+		}
+		if drift := report.Drift(); drift > 0 {
+			stats.Drift = append(stats.Drift, UserDrift{Username: report.User.Username, Drift: drift})
+		}
+	}
+
+	stats.MissingChannels = topCounts(missingChannels, top)
+	stats.MissingCategories = topCounts(missingCategories, top)
+
+	sort.SliceStable(stats.Drift, func(i, j int) bool {
+		if stats.Drift[i].Drift != stats.Drift[j].Drift {
+			return stats.Drift[i].Drift > stats.Drift[j].Drift
+		}
+		return stats.Drift[i].Username < stats.Drift[j].Username
+	})
+	if len(stats.Drift) > top {
+		stats.Drift = stats.Drift[:top]
+	}
+
+	return stats
+}
+
+// static
+
+// topCounts orders the counts, highest first and then by name, keeping the top.
+func topCounts(counts map[string]int, top int) []Count {
+	list := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		list = append(list, Count{Name: name, Count: count})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Name < list[j].Name
+	})
+	if len(list) > top {
+		list = list[:top]
+	}
+	return list
+}
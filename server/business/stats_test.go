@@ -0,0 +1,46 @@
+package business
+
+import (
+	"github.com/mattermost/mattermost-server/v6/model"
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeReports(t *testing.T) {
+	reports := []*StructureReport{
+		{User: &model.User{Username: "admin"}},
+		{User: &model.User{Username: "skipper"}, MissingChannels: []string{"Laser"}},
+		{User: &model.User{Username: "bosun"}, MissingChannels: []string{"Laser", "Buzz"}, MissingCategories: []string{"Fleet"},
+			WronglyCategorized: []Miscategorization{{Channel: "Fox", Expected: "Fleet", Actual: "Channels"}}},
+		{User: &model.User{Username: "mate"}, MissingChannels: []string{"Fox"}, UnresolvedChannels: []string{"Old"}},
+	}
+
+	stats := SummarizeReports(reports, 2)
+
+	if stats.Users != 4 || stats.Compliant != 1 || stats.CompliantPercent() != 25 {
+		t.Errorf("compliant = %d of %d (%.0f%%), want 1 of 4", stats.Compliant, stats.Users, stats.CompliantPercent())
+	}
+	if expected := []Count{{"Laser", 2}, {"Buzz", 1}}; !reflect.DeepEqual(stats.MissingChannels, expected) {
+		t.Errorf("missing channels = %v, want %v", stats.MissingChannels, expected)
+	}
+	if expected := []Count{{"Fleet", 1}}; !reflect.DeepEqual(stats.MissingCategories, expected) {
+		t.Errorf("missing categories = %v, want %v", stats.MissingCategories, expected)
+	}
+	if expected := []UserDrift{{"bosun", 4}, {"mate", 1}}; !reflect.DeepEqual(stats.Drift, expected) {
+		t.Errorf("drift = %v, want %v", stats.Drift, expected)
+	}
+}
+
+func TestComplianceStatsOfTeam(t *testing.T) {
+	f := newFixture(t)
+	f.onboard(t, f.addUser("skipper"))
+	f.addUser("bosun")
+
+	stats, err := WrapTeam(f.c, f.team).ComplianceStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Users != 3 || stats.Compliant != 1 {
+		t.Errorf("compliant = %d of %d, want only skipper of 3", stats.Compliant, stats.Users)
+	}
+}
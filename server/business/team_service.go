@@ -1,45 +1,34 @@
 package business
 
 import (
-	"fmt"
 	"github.com/glass.plugin-anchor/server/config"
 	"github.com/glass.plugin-anchor/server/models"
 	"github.com/mattermost/mattermost-server/v6/model"
-	"strings"
 )
 
 type Team struct {
 	c *models.Context
 	*model.Team
+	channels *ChannelIndex
 }
 
 // Constructors
 
 func WrapTeam(c *models.Context, team *model.Team) *Team {
-	return &Team{c, team}
+	return &Team{c, team, NewChannelIndex(c, team.Id)}
 }
 
-func (t *Team) GetChannelsListString() string {
-
-	channels, err := t.getChannels()
-	if err != nil {
-		// Return the error message as part of the string
-		return fmt.Sprintf("Error fetching channels: %v", err)
-	}
-
-	// Use a string builder for efficient string concatenation
-	var builder strings.Builder
-
-	// Loop through all channels and append their names to the builder
-	for _, channel := range channels {
-		builder.WriteString(fmt.Sprintf("%s\n", channel.DisplayName))
+// NewUser looks up a user by name, sharing the channel index of the team.
+func (t *Team) NewUser(userName string) (*User, error) {
+	user, appErr := t.c.API.GetUserByUsername(userName)
+	if appErr != nil {
+		return nil, apiError("find user", userName, appErr)
 	}
-
-	// Convert the builder to a string and return it
-	return builder.String()
+	return newUserWithIndex(t.c, user, t.channels), nil
 }
 
-func (t *Team) getChannels() ([]*model.Channel, error) {
+// PublicChannels lists all public channels of the team.
+func (t *Team) PublicChannels() ([]*model.Channel, error) {
 	var allChannels []*model.Channel
 	page := 0
 	perPage := 100 // You can adjust this to change how many channels are fetched per page
@@ -48,7 +37,7 @@ func (t *Team) getChannels() ([]*model.Channel, error) {
 		// Get channels for the current page in the team
 		channels, appErr := t.c.API.GetPublicChannelsForTeam(t.Team.Id, page, perPage)
 		if appErr != nil {
-			return nil, appErr
+			return nil, apiError("list public channels of team", t.Name, appErr)
 		}
 
 		// If no channels are returned, we've retrieved all of them
@@ -66,15 +55,18 @@ func (t *Team) getChannels() ([]*model.Channel, error) {
 	return allChannels, nil
 }
 
-func (t *Team) CheckUserChannelStructure() string {
-	var resultBuilder strings.Builder
+// CheckUserChannelStructure checks the structure of every member of the team.
+// Members whose check failed are reported as a partial failure.
+func (t *Team) CheckUserChannelStructure() ([]*StructureReport, error) {
+	var reports []*StructureReport
+	failed := failures{op: "check members"}
 
 	page := 0
 	perPage := 100
 	for {
 		users, appErr := t.c.API.GetUsersInTeam(t.Team.Id, page, perPage)
 		if appErr != nil {
-			return "Unable to retrieve users in the team."
+			return nil, apiError("list members of team", t.Name, appErr)
 		}
 
 		if len(users) == 0 {
@@ -82,66 +74,70 @@ func (t *Team) CheckUserChannelStructure() string {
 		}
 
 		for _, user := range users {
-			u := WrapUser(t.c, user)
+			u := newUserWithIndex(t.c, user, t.channels)
 			s, err := NewSideBar(u)
 			if err != nil {
-				return fmt.Sprintf("Error creating side-bar: %v", err)
+				failed.add(user.Username, err)
+				continue
 			}
-			userStructureResult := s.CheckChannelStructure()
-			resultBuilder.WriteString(userStructureResult + "\n")
+			report, err := s.CheckChannelStructure()
+			if err != nil {
+				failed.add(user.Username, err)
+				continue
+			}
+			reports = append(reports, report)
 		}
 
 		page++
 	}
 
-	return resultBuilder.String()
+	return reports, failed.err()
 }
 
-func GetTeamsListString(c *models.Context) string {
-
-	teams, err := c.API.GetTeams()
+func ListTeams(c *models.Context) ([]*model.Team, error) {
 
-	if err != nil {
-		return "Don't know!"
-	} else if len(teams) == 0 {
-		return "No teams"
-	} else {
-		var teamNames []string
-		for _, team := range teams {
-			teamNames = append(teamNames, team.Name)
-		}
-		return strings.Join(teamNames, "\n")
+	teams, appErr := c.API.GetTeams()
+	if appErr != nil {
+		return nil, apiError("list teams", "", appErr)
 	}
+	return teams, nil
 }
 
 // private
 
-func (t *Team) CreateDefaultChannels() string {
-	var result string
+// CreateDefaultChannels creates the public channels of the structure and
+// returns the names of the channels created.
+func (t *Team) CreateDefaultChannels() ([]string, error) {
+	var created []string
+	failed := failures{op: "create channels"}
+
+	// Loop through the public channels of the structure in order
+	for _, category := range config.PublicStructure() {
+		for _, entry := range category.Channels {
+			if entry.Name == "" {
+				failed.add(entry.String(), NewError(KindInvalid, "create channel", entry.String(), errNoURLName))
+				continue
+			}
 
-	// Loop through the config's PublicChannels map
-	for _, channels := range config.PublicChannels {
-		for _, channelName := range channels {
 			// Define a new channel to create
 			channel := &model.Channel{
 				TeamId:      t.Team.Id,
-				Name:        createChannelName(channelName), // Convert name to a valid channel name
-				DisplayName: channelName,
+				Name:        entry.Name,
+				DisplayName: entry.DisplayName,
 				Type:        model.ChannelTypeOpen, // Public channel
 			}
 
 			// Create the channel using the Mattermost API
 			_, appErr := t.c.API.CreateChannel(channel)
 			if appErr != nil {
-				// If an error occurs, append it to the result and continue
-				result += fmt.Sprintf("Failed to create channel %s: %v\n", channelName, appErr.Error())
+				// If an error occurs, record it and continue
+				failed.add(entry.String(), apiError("create channel", entry.String(), appErr))
 				continue
 			}
 
-			// Append success message to the result
-			result += fmt.Sprintf("Created channel: %s\n", channelName)
+			created = append(created, entry.String())
 		}
 	}
 
-	return result
+	return created, failed.err()
 }
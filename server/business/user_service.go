@@ -1,52 +1,43 @@
 package business
 
 import (
-	"fmt"
 	"github.com/glass.plugin-anchor/server/config"
 	"github.com/glass.plugin-anchor/server/models"
+	"github.com/glass.plugin-anchor/server/utils"
 	"github.com/mattermost/mattermost-server/v6/model"
-	"strings"
 )
 
 type User struct {
 	c *models.Context
 	*model.User
+	channels *ChannelIndex
+
+	audience  audienceProfile
+	structure []config.Category
 }
 
 // Constructors
 
 func WrapUser(c *models.Context, user *model.User) *User {
-	return &User{c, user}
+	return &User{c: c, User: user, channels: NewChannelIndex(c, c.Team.Id)}
 }
 
 func NewUser(c *models.Context, userName string) (*User, error) {
-	user, err := c.API.GetUserByUsername(userName)
-	if err != nil {
-		return nil, err
+	user, appErr := c.API.GetUserByUsername(userName)
+	if appErr != nil {
+		return nil, apiError("find user", userName, appErr)
 	}
-	return &User{c: c, User: user}, nil
+	return WrapUser(c, user), nil
 }
 
-// static
-
-func GetUserListString(c *models.Context) string {
-
-	users, err := listAllUsers(c)
-
-	if err != nil {
-		return "Don't know!"
-	} else if len(users) == 0 {
-		return "No users"
-	} else {
-		var userNames []string
-		for _, user := range users {
-			userNames = append(userNames, user.Username)
-		}
-		return strings.Join(userNames, "\n")
-	}
+// newUserWithIndex wraps a user sharing the channel index of the current operation.
+func newUserWithIndex(c *models.Context, user *model.User, channels *ChannelIndex) *User {
+	return &User{c: c, User: user, channels: channels}
 }
 
-func listAllUsers(c *models.Context) ([]*model.User, error) {
+// static
+
+func ListUsers(c *models.Context) ([]*model.User, error) {
 	var allUsers []*model.User
 	page := 0
 	perPage := 50 // number of users per page
@@ -57,7 +48,7 @@ func listAllUsers(c *models.Context) ([]*model.User, error) {
 			PerPage: perPage,
 		})
 		if appErr != nil {
-			return nil, fmt.Errorf("failed to get users: %w", appErr)
+			return nil, apiError("list users", "", appErr)
 		}
 
 		if len(users) == 0 {
@@ -76,91 +67,118 @@ func listAllUsers(c *models.Context) ([]*model.User, error) {
 func (u *User) GetSubscribedPublicChannels() ([]*model.Channel, error) {
 	var publicChannels []*model.Channel
 
-	// Get all public channels for the team
-	channels, appErr := u.c.API.GetPublicChannelsForTeam(u.c.Team.Id, 0, 10000) // Adjust the limit if necessary
-	if appErr != nil {
-		return nil, appErr
+	// Get all channels of the team the user is a member of
+	channels, err := u.channels.MemberChannels(u.Id)
+	if err != nil {
+		return nil, err
 	}
 
-	// Iterate over the channels and check if the user is a member of the public channel
+	// Keep the public ones
 	for _, channel := range channels {
 		if channel.Type == model.ChannelTypeOpen { // Public channel type
-			_, memberErr := u.c.API.GetChannelMember(channel.Id, u.Id)
-			if memberErr == nil { // If the user is a member, add to the list
-				publicChannels = append(publicChannels, channel)
-			}
+			publicChannels = append(publicChannels, channel)
 		}
 	}
 
 	return publicChannels, nil
 }
 
-func (u *User) checkChannelSubscription() string {
-	// Get the list of public channels the user is subscribed to
-	publicChannels, err := u.GetSubscribedPublicChannels()
-	if err != nil {
-		return "Unable to retrieve user subscribed public channels."
-	}
-
-	// Convert the list of public channel names the user is subscribed to into a map for easier lookup
-	subscribedChannelNames := make(map[string]bool)
-	for _, channel := range publicChannels {
-		subscribedChannelNames[channel.DisplayName] = true
-	}
-
-	// Get the default channel names
-	defaultChannelNames := config.ChannelNames()
-
-	// Create a slice to accumulate missing channels
-	var missingChannels []string
-
-	// Check if all default channels are present in the user's subscribed public channels
-	for _, defaultChannel := range defaultChannelNames {
-		if !subscribedChannelNames[defaultChannel] {
-			missingChannels = append(missingChannels, defaultChannel)
+// checkChannelSubscription returns the channels of the structure meant for the
+// user they are not a member of, and the entries that could not be resolved.
+func (u *User) checkChannelSubscription(structure []config.Category) (missing []string, unresolved []string, err error) {
+	// Check if the user is a member of all channels meant for them
+	for _, category := range structure {
+		for _, entry := range category.Channels {
+			channel, err := u.channels.Resolve(entry)
+			if err != nil {
+				unresolved = append(unresolved, err.Error())
+				continue
+			}
+			isMember, err := u.channels.IsMember(u.Id, channel.Id)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !isMember {
+				missing = append(missing, entry.String())
+			}
 		}
 	}
 
-	// If no channels are missing, return a success message
-	if len(missingChannels) == 0 {
-		//return "User is subscribed to all required channels."
-		return "."
+	return missing, unresolved, nil
+}
 
-	}
+// JoinResult lists the channels a user was added to, those they already were a
+// member of and those whose notification preferences were preset.
+type JoinResult struct {
+	Joined        []string
+	AlreadyMember []string
+	Notified      []string
+}
 
-	// Return the missing channels as a comma-separated string
-	return "Missing required channels: " + strings.Join(missingChannels, ", ")
+// presetPending tells whether the channel was joined without applying its
+// preset.
+func (r *JoinResult) presetPending(channel string, entry config.Channel) bool {
+	return r != nil && !entry.Notify.IsZero() && utils.Contains(r.Joined, channel) && !utils.Contains(r.Notified, channel)
 }
 
-func (u *User) JoinMissingChannels(categoryChannels map[string][]string) string {
-	var resultBuilder strings.Builder
+// JoinMissingChannels adds the user to the channels of the categories, with the
+// notification preferences preset for the channel. An earlier attempt, if any,
+// tells the channels it joined whose preset still needs to be applied. Channels
+// that could not be resolved or joined are reported as a partial failure.
+func (u *User) JoinMissingChannels(categories []config.Category, earlier *JoinResult) (*JoinResult, error) {
+	result := &JoinResult{}
+	failed := failures{op: "join channels"}
 
 	// Loop through the categories and their corresponding channels
-	for _, channels := range categoryChannels {
-		for _, displayName := range channels {
-			// Get the channel by display name and team ID
-			channel, appErr := GetChannelByDisplayName(u.c, displayName)
-			if appErr != nil || channel == nil {
-				resultBuilder.WriteString(fmt.Sprintf("Channel not found: %s\n", displayName))
+	for _, category := range categories {
+		for _, entry := range category.Channels {
+			displayName := entry.String()
+
+			channel, err := u.channels.Resolve(entry)
+			if err != nil {
+				failed.add(displayName, err)
 				continue
 			}
 
 			// Check if the user is already a member of the channel
-			_, appErr = u.c.API.GetChannelMember(channel.Id, u.Id)
-			if appErr != nil {
-				// If the user is not a member, add them to the channel
-				resultBuilder.WriteString(fmt.Sprintf("User is not a member of %s. Adding to channel...\n", displayName))
-				_, addErr := u.c.API.AddChannelMember(channel.Id, u.Id)
-				if addErr != nil {
-					resultBuilder.WriteString(fmt.Sprintf("Failed to add user to channel: %s\n", displayName))
-				} else {
-					resultBuilder.WriteString(fmt.Sprintf("Successfully added user to channel: %s\n", displayName))
+			isMember, err := u.channels.IsMember(u.Id, channel.Id)
+			if err != nil {
+				failed.add(displayName, err)
+				continue
+			}
+			if isMember {
+				result.AlreadyMember = append(result.AlreadyMember, displayName)
+				if earlier.presetPending(displayName, entry) {
+					if err := u.setNotifyProps(channel, entry.Notify); err != nil {
+						failed.add(displayName, err)
+						continue
+					}
+					result.Notified = append(result.Notified, displayName)
 				}
-			} else {
-				resultBuilder.WriteString(fmt.Sprintf("User is already a member of channel: %s\n", displayName))
+				continue
+			}
+
+			// If the user is not a member, the bot adds them to the channel
+			if err := ExpectMembershipPost(u.c, channel.Id, u.Id); err != nil {
+				u.c.API.LogWarn("Membership message will be posted", "channel", displayName, "error", err.Error())
+			}
+			if _, appErr := u.c.API.AddUserToChannel(channel.Id, u.Id, u.c.BotID); appErr != nil {
+				failed.add(displayName, apiError("add user to channel", displayName, appErr))
+				continue
+			}
+			u.channels.AddMember(u.Id, channel)
+			result.Joined = append(result.Joined, displayName)
+
+			if entry.Notify.IsZero() {
+				continue
+			}
+			if err := u.setNotifyProps(channel, entry.Notify); err != nil {
+				failed.add(displayName, err)
+				continue
 			}
+			result.Notified = append(result.Notified, displayName)
 		}
 	}
 
-	return resultBuilder.String()
+	return result, failed.err()
 }
@@ -1,20 +1,19 @@
 package business
 
 import (
-	"errors"
 	"fmt"
 	"github.com/glass.plugin-anchor/server/config"
 	"github.com/glass.plugin-anchor/server/models"
 	"github.com/glass.plugin-anchor/server/utils"
 	"github.com/mattermost/mattermost-server/v6/model"
-	"strings"
 )
 
 type SideBar struct {
-	c          *models.Context
-	u          *User
-	User       *model.User
-	categories *model.OrderedSidebarCategories
+	c           *models.Context
+	u           *User
+	User        *model.User
+	categories  *model.OrderedSidebarCategories
+	preferences *Preferences
 }
 
 func NewSideBar(user *User) (*SideBar, error) {
@@ -36,28 +35,48 @@ func NewSideBar(user *User) (*SideBar, error) {
 	return sidebar, nil
 }
 
-func (s *SideBar) fetch() *model.AppError {
-	var err *model.AppError
-	s.categories, err = s.c.API.GetChannelSidebarCategories(s.u.Id, s.c.Team.Id)
-	return err
+func (s *SideBar) fetch() error {
+	var appErr *model.AppError
+	s.categories, appErr = s.c.API.GetChannelSidebarCategories(s.u.Id, s.c.Team.Id)
+	return apiError("get sidebar categories of user", s.User.Username, appErr)
 }
 
-func (s *SideBar) SidebarCategoryNames() ([]string, error) {
+func (s *SideBar) SidebarCategoryNames() []string {
 	var categories []string
 
 	for _, category := range s.categories.Categories {
 		categories = append(categories, category.DisplayName)
 	}
 
-	return categories, nil
+	return categories
 }
 
-func (s *SideBar) checkSidebarCategories() string {
+// StructureReport is the outcome of checking the sidebar and channels of a
+// user against the structure.
+type StructureReport struct {
+	User               *model.User
+	MissingCategories  []string
+	MissingChannels    []string
+	UnresolvedChannels []string
+	WronglyCategorized []Miscategorization
+}
+
+// Miscategorization is a channel found in another category than configured.
+type Miscategorization struct {
+	Channel  string
+	Expected string
+	Actual   string
+}
+
+func (r *StructureReport) Compliant() bool {
+	return len(r.MissingCategories) == 0 && len(r.MissingChannels) == 0 && len(r.WronglyCategorized) == 0
+}
+
+// checkSidebarCategories returns the categories of the structure missing from
+// the sidebar.
+func (s *SideBar) checkSidebarCategories(structure []config.Category) []string {
 	// Get the list of category names in the user's sidebar for the given team
-	userCategories, err := s.SidebarCategoryNames()
-	if err != nil {
-		return "Unable to retrieve user sidebar categories."
-	}
+	userCategories := s.SidebarCategoryNames()
 
 	// Convert the list of user's sidebar category names into a map for easier lookup
 	userCategoryMap := make(map[string]bool)
@@ -65,92 +84,75 @@ func (s *SideBar) checkSidebarCategories() string {
 		userCategoryMap[category] = true
 	}
 
-	// Get the default category names
-	defaultCategoryNames := config.CategoryNames()
-
 	// Create a slice to accumulate missing categories
 	var missingCategories []string
 
 	// Check if all default categories are present in the user's sidebar categories
-	for _, defaultCategory := range defaultCategoryNames {
-		if !userCategoryMap[defaultCategory] {
-			missingCategories = append(missingCategories, defaultCategory)
+	for _, category := range structure {
+		if !userCategoryMap[category.Name] {
+			missingCategories = append(missingCategories, category.Name)
 		}
 	}
 
-	// If no categories are missing, return a success message
-	if len(missingCategories) == 0 {
-		//return "All required categories are present."
-		return "."
-
-	}
-
-	// Return the missing categories as a comma-separated string
-	return "Missing required categories: " + strings.Join(missingCategories, ", ")
+	return missingCategories
 }
 
-func (s *SideBar) checkChannelCategorization() string {
-	// Get the list of public channels the user is subscribed to
-	publicChannels, err := s.u.GetSubscribedPublicChannels()
+// checkChannelCategorization returns the subscribed channels of the structure
+// that are in another category than configured.
+func (s *SideBar) checkChannelCategorization(structure []config.Category) ([]Miscategorization, error) {
+	// Get the list of channels the user is subscribed to
+	memberChannels, err := s.u.channels.MemberChannels(s.User.Id)
 	if err != nil {
-		return "Unable to retrieve user subscribed public channels."
+		return nil, err
 	}
 
-	// Create a map to hold the expected category for each channel from ChannelTree
+	// Create a map to hold the expected category for each channel ID from ChannelTree
 	expectedCategoryMap := make(map[string]string)
-	for category, channels := range config.PublicChannels {
-		for _, channel := range channels {
-			expectedCategoryMap[channel] = category
+	for _, category := range structure {
+		for _, entry := range category.Channels {
+			channel, err := s.u.channels.Resolve(entry)
+			if err != nil {
+				continue // Unresolved channels are reported by checkChannelSubscription
+			}
+			expectedCategoryMap[channel.Id] = category.Name
 		}
 	}
 
 	// Create a slice to store any wrongly categorized channels
-	var wronglyCategorized []string
+	var wronglyCategorized []Miscategorization
 
-	// Get the user's actual sidebar categories from the API
-	userSidebarCategories, appErr := s.c.API.GetChannelSidebarCategories(s.User.Id, s.c.Team.Id)
-	if appErr != nil {
-		return "Unable to retrieve user sidebar categories."
-	}
-
-	// Map actual categories from the sidebar for easier lookup
+	// Map actual categories from the sidebar fetched with the side-bar for easier lookup
 	userCategoryMap := make(map[string]string)
-	for _, sidebarCategory := range userSidebarCategories.Categories {
+	for _, sidebarCategory := range s.categories.Categories {
 		for _, channelId := range sidebarCategory.Channels {
-			channel, err := s.c.API.GetChannel(channelId)
+			channel, err := s.u.channels.Channel(channelId)
 			if err == nil {
-				userCategoryMap[channel.DisplayName] = sidebarCategory.DisplayName
+				userCategoryMap[channel.Id] = sidebarCategory.DisplayName
 			}
 		}
 	}
 
 	// Check if each subscribed channel is in the expected category
-	for _, channel := range publicChannels {
-		expectedCategory, exists := expectedCategoryMap[channel.DisplayName]
+	for _, channel := range memberChannels {
+		expectedCategory, exists := expectedCategoryMap[channel.Id]
 		if !exists {
 			continue // If the channel is not in the ChannelTree, skip the check
 		}
 
-		actualCategory, isCategorized := userCategoryMap[channel.DisplayName]
+		actualCategory, isCategorized := userCategoryMap[channel.Id]
 		if isCategorized && actualCategory != expectedCategory {
-			wronglyCategorized = append(wronglyCategorized, channel.DisplayName+" (expected: "+expectedCategory+", got: "+actualCategory+")")
+			wronglyCategorized = append(wronglyCategorized, Miscategorization{channel.DisplayName, expectedCategory, actualCategory})
 		}
 	}
 
-	// If no channels are wrongly categorized, return a success message
-	if len(wronglyCategorized) == 0 {
-		return "."
-	}
-
-	// Return the wrongly categorized channels as a comma-separated string
-	return "Wrongly categorized channels: " + strings.Join(wronglyCategorized, ", ")
+	return wronglyCategorized, nil
 }
 
 func (s *SideBar) getOrCreateSidebarCategory(categoryName string) (*model.SidebarCategoryWithChannels, error) {
 	// Fetch the user's sidebar categories for the specified team
 	categories, appErr := s.c.API.GetChannelSidebarCategories(s.User.Id, s.c.Team.Id)
 	if appErr != nil {
-		return nil, appErr
+		return nil, apiError("get sidebar categories of user", s.User.Username, appErr)
 	}
 
 	// Look for the category by name
@@ -160,7 +162,7 @@ func (s *SideBar) getOrCreateSidebarCategory(categoryName string) (*model.Sideba
 		}
 	}
 
-	// If the category does not exist, create it
+	// If the category does not exist, create it with the configured policy
 	newCategory := &model.SidebarCategoryWithChannels{
 		SidebarCategory: model.SidebarCategory{
 			UserId:      s.User.Id,
@@ -169,96 +171,50 @@ func (s *SideBar) getOrCreateSidebarCategory(categoryName string) (*model.Sideba
 			Type:        model.SidebarCategoryCustom, // Custom category
 		},
 	}
+	applyCategoryPolicy(newCategory)
 
 	createdCategory, appErr := s.c.API.CreateChannelSidebarCategory(s.User.Id, s.c.Team.Id, newCategory)
 	if appErr != nil {
-		return nil, appErr
+		return nil, apiError("create sidebar category", categoryName, appErr)
 	}
 
 	return createdCategory, nil
 }
 
-func (s *SideBar) addChannelToSidebarCategory(category *model.SidebarCategoryWithChannels, channelID string) *model.AppError {
-	// Get the current list of channel IDs from the category using ChannelIds()
-	channelIDs := category.ChannelIds()
-
-	// Check if the channel is already in the category
-	for _, existingChannelID := range channelIDs {
-		if existingChannelID == channelID {
-			// Channel is already in the category, no need to update
-			return nil
-		}
-	}
-
-	// Add the channel ID to the list of channel IDs
-	newChannelIDs := append(channelIDs, channelID)
-
-	// Create a new category with the updated channel list
-	updatedCategory := &model.SidebarCategoryWithChannels{
-		SidebarCategory: model.SidebarCategory{
-			Id:          category.Id,
-			UserId:      category.UserId,
-			TeamId:      category.TeamId,
-			DisplayName: category.DisplayName,
-			Type:        category.Type,
-		},
-		Channels: newChannelIDs, // Assign the updated channel list
-	}
-
-	// Update the sidebar category with the new channel list
-	_, appErr := s.c.API.UpdateChannelSidebarCategories(s.User.Id, s.c.Team.Id, []*model.SidebarCategoryWithChannels{updatedCategory})
-	return appErr
-}
-
-func (s *SideBar) CheckAndJoinDefaultChannelStructure() string {
-
-	resultChannels := s.u.JoinMissingChannels(config.PublicChannels)
-	resultJoin := s.u.JoinMissingChannels(config.PublicChannels)
-
-	sidebarCategories := s.createMissingSidebarCategories(config.PublicChannels)
-	resultCategories := s.assignChannelsToCategories(sidebarCategories, config.PublicChannels)
-
-	resultReorder := s.ReorderSidebarCategories()
-
-	return strings.Join([]string{
-		resultChannels,
-		resultJoin,
-		resultCategories,
-		resultReorder,
-	}, "\n")
-
-}
-
-func (s *SideBar) createMissingSidebarCategories(categoryChannels map[string][]string) map[string]*model.SidebarCategoryWithChannels {
-	sidebarCategories := make(map[string]*model.SidebarCategoryWithChannels)
+func (s *SideBar) createMissingSidebarCategories(categories []config.Category) error {
 	var orderedCategories []*model.SidebarCategoryWithChannels
+	failed := failures{op: "create sidebar categories"}
 
-	// Iterate over the categories in the order defined in PublicChannels
-	for category, channels := range categoryChannels {
+	// Iterate over the categories in the order defined in the structure
+	for _, category := range categories {
 		// Get or create the sidebar category for the user in the specified team
-		sidebarCategory, err := s.getOrCreateSidebarCategory(category)
+		sidebarCategory, err := s.getOrCreateSidebarCategory(category.Name)
 		if err != nil {
+			failed.add(category.Name, err)
 			continue
 		}
 
 		// Create a slice to hold the channel IDs
 		var channelIDs []string
 
-		// Populate the slice with channel IDs by fetching them using their names
-		for _, channelName := range channels {
-			channel, appErr := s.c.API.GetChannelByNameForTeamName(s.c.Team.Name, channelName, false)
-			if appErr != nil {
-				// Log or handle the error, but skip this channel if it fails
+		// Populate the slice with channel IDs by resolving the configured entries
+		for _, entry := range category.Channels {
+			channel, err := s.u.channels.Resolve(entry)
+			if err != nil {
+				// Unresolved channels are reported when joining them
 				continue
 			}
 			channelIDs = append(channelIDs, channel.Id)
 		}
 
-		// Update the category's channel list with the appropriate channel IDs
-		sidebarCategory.Channels = channelIDs
+		// Keep the channels already in the category and add the configured ones
+		sidebarCategory.Channels = appendUnique(sidebarCategory.Channels, channelIDs...)
 
-		// Store the category in the result map for returning later
-		sidebarCategories[category] = sidebarCategory
+		// New categories got their policy on creation; existing ones keep the
+		// choices of the user unless policies are enforced
+		if config.EnforceCategoryPolicies && !s.respectsCategorySettings() {
+			applyCategoryPolicy(sidebarCategory)
+		}
 
 		// Append the category to the ordered list to apply all at once
 		orderedCategories = append(orderedCategories, sidebarCategory)
@@ -270,46 +226,59 @@ func (s *SideBar) createMissingSidebarCategories(categoryChannels map[string][]s
 	}
 
 	// Now, apply all the created/retrieved categories in one batch API call
-	_, appErr := s.c.API.UpdateChannelSidebarCategories(s.User.Id, s.c.Team.Id, orderedCategories)
-	if appErr != nil {
-		// Handle error (logging, etc.)
+	if len(orderedCategories) > 0 {
+		_, appErr := s.c.API.UpdateChannelSidebarCategories(s.User.Id, s.c.Team.Id, orderedCategories)
+		if appErr != nil {
+			failed.add("", apiError("update sidebar categories of user", s.User.Username, appErr))
+		}
 	}
 
-	return sidebarCategories
+	return failed.err()
+}
+
+// CategoryAssignment lists the channels added to a managed category and those
+// that already were in it.
+type CategoryAssignment struct {
+	Category string
+	Added    []string
+	Present  []string
 }
 
-func (s *SideBar) assignChannelsToCategories(sidebarCategories map[string]*model.SidebarCategoryWithChannels, categoryChannels map[string][]string) string {
-	var resultBuilder strings.Builder
+func (s *SideBar) assignChannelsToCategories(categories []config.Category) ([]CategoryAssignment, error) {
+	var assignments []CategoryAssignment
+	failed := failures{op: "assign channels to categories"}
+
+	if err := s.fetch(); err != nil {
+		return nil, err
+	}
+
+	sidebarCategories := managedCategories(s.categories)
 
 	// Loop through the categories and assign channels
-	for category, channels := range categoryChannels {
-		sidebarCategory := sidebarCategories[category]
+	for _, managed := range categories {
+		category := managed.Name
+		sidebarCategory, exists := sidebarCategories[category]
+		if !exists {
+			failed.add(category, NewError(KindNotFound, "find sidebar category", category, nil))
+			continue
+		}
 		channelIDs := sidebarCategory.ChannelIds()
+		assignment := CategoryAssignment{Category: category}
 
 		// Collect all new channel IDs that need to be added to the category
-		for _, displayName := range channels {
-			// Get the channel by display name and team ID
-			channel, appErr := GetChannelByDisplayName(s.c, displayName)
-			if appErr != nil || channel == nil {
-				resultBuilder.WriteString(fmt.Sprintf("Channel not found: %s\n", displayName))
+		for _, entry := range managed.Channels {
+			channel, err := s.u.channels.Resolve(entry)
+			if err != nil {
+				// Unresolved channels are reported when joining them
 				continue
 			}
 
-			// Check if the channel is already in the category
-			channelAlreadyInCategory := false
-			for _, existingChannelID := range channelIDs {
-				if existingChannelID == channel.Id {
-					channelAlreadyInCategory = true
-					break
-				}
-			}
-
 			// If the channel is not in the category, add it to the list
-			if !channelAlreadyInCategory {
+			if !utils.Contains(channelIDs, channel.Id) {
 				channelIDs = append(channelIDs, channel.Id)
-				resultBuilder.WriteString(fmt.Sprintf("Queued channel %s to be added to category %s\n", displayName, category))
+				assignment.Added = append(assignment.Added, entry.String())
 			} else {
-				resultBuilder.WriteString(fmt.Sprintf("Channel %s already in category %s\n", displayName, category))
+				assignment.Present = append(assignment.Present, entry.String())
 			}
 		}
 
@@ -322,96 +291,246 @@ func (s *SideBar) assignChannelsToCategories(sidebarCategories map[string]*model
 		// Apply the batch update
 		_, appErr := s.c.API.UpdateChannelSidebarCategories(s.User.Id, s.c.Team.Id, []*model.SidebarCategoryWithChannels{sidebarCategoryWithUpdatedChannels})
 		if appErr != nil {
-			resultBuilder.WriteString(fmt.Sprintf("Failed to update sidebar category %s: %s\n", category, appErr.Error()))
-		} else {
-			resultBuilder.WriteString(fmt.Sprintf("Successfully updated sidebar category %s with all channels\n", category))
+			failed.add(category, apiError("update sidebar category", category, appErr))
+			continue
 		}
+		assignments = append(assignments, assignment)
 	}
 
-	return resultBuilder.String()
+	return assignments, failed.err()
 }
 
-func (s *SideBar) findCategory(categoryName string) (*model.SidebarCategoryWithChannels, error) {
-	var matchedCategory *model.SidebarCategoryWithChannels
+// categoryChannelIDs returns the IDs of the channels of the category in the
+// structure meant for the user, in their configured order.
+func categoryChannelIDs(channels *ChannelIndex, structure []config.Category, categoryName string) []string {
+	var orderedChannelIDs []string
 
-	actualCategories, err := s.c.API.GetChannelSidebarCategories(s.User.Id, s.c.Team.Id)
+	for _, category := range structure {
+		if category.Name != categoryName {
+			continue
+		}
+		for _, entry := range category.Channels {
 
-	if err != nil {
-		return nil, err
+			channel, err := channels.Resolve(entry)
+			if err != nil {
+				continue
+			}
+			orderedChannelIDs = append(orderedChannelIDs, channel.Id)
+		}
 	}
+	return orderedChannelIDs
+}
 
-	for _, category := range actualCategories.Categories {
-		if category.DisplayName == categoryName {
-			matchedCategory = category
-			break
+// managedChannelCategories maps the IDs of the channels in the structure meant
+// for the user to the name of the category they belong to. Channels of the
+// structure not meant for the user, such as private channels without an
+// audience, are not managed for them and stay where the user put them.
+func managedChannelCategories(channels *ChannelIndex, structure []config.Category) map[string]string {
+	managed := make(map[string]string)
+
+	for _, category := range structure {
+		for _, entry := range category.Channels {
+			channel, err := channels.Resolve(entry)
+			if err != nil {
+				continue
+			}
+			if _, exists := managed[channel.Id]; !exists {
+				managed[channel.Id] = category.Name
+			}
 		}
 	}
-	return matchedCategory, nil
+	return managed
 }
 
-func categoryChannelIDs(c *models.Context, categoryName string) ([]string, error) {
-	var orderedChannelIDs []string
+// withoutManagedChannels drops the channels that are managed by another
+// category than the given one, keeping the order of the remaining ones.
+func withoutManagedChannels(channelIDs []string, managed map[string]string, categoryName string) []string {
+	var kept []string
 
-	channelNames, exists := config.AllChannels()[categoryName]
-	if !exists {
-		return nil, errors.New("category not found " + categoryName)
+	for _, channelID := range channelIDs {
+		if owner, isManaged := managed[channelID]; isManaged && owner != categoryName {
+			continue
+		}
+		kept = append(kept, channelID)
 	}
+	return kept
+}
 
-	for _, channelName := range channelNames {
-
-		channel, err := GetChannelByDisplayName(c, channelName)
-		if err != nil {
-			continue
+// appendUnique appends the channel IDs not yet in the list.
+func appendUnique(channelIDs []string, more ...string) []string {
+	for _, channelID := range more {
+		if !utils.Contains(channelIDs, channelID) {
+			channelIDs = append(channelIDs, channelID)
 		}
-		orderedChannelIDs = append(orderedChannelIDs, channel.Id)
 	}
-	return orderedChannelIDs, nil
+	return channelIDs
 }
 
-func (s *SideBar) ReorderSidebarCategories() string {
-	var dbg []string
+// ReorderResult lists the order of the categories before and after
+// reordering.
+type ReorderResult struct {
+	Before []CategorySortOrder
+	After  []CategorySortOrder
+}
 
-	s.c.API.LogDebug("XXXXX ReorderSidebarCategories", s.User.Username, s.c.Team.Id)
+type CategorySortOrder struct {
+	Category  string
+	SortOrder int64
+}
 
-	var updatedCategories []*model.SidebarCategoryWithChannels
+// ReorderSidebarCategories puts the configured channels first in their managed
+// categories and moves managed channels out of the user's own categories, then
+// orders all categories, the built-in ones included, as config.SidebarOrder
+// says through the category order endpoint.
+func (s *SideBar) ReorderSidebarCategories() (*ReorderResult, error) {
+	result := &ReorderResult{}
+
+	if err := s.fetch(); err != nil {
+		return nil, err
+	}
 
-	for index, categoryName := range config.CategoryOrder {
+	structure, err := s.u.Structure()
+	if err != nil {
+		return nil, err
+	}
 
-		category, err := s.findCategory(categoryName)
-		if err != nil {
-			return "err.Error()"
+	for _, category := range s.categories.Categories {
+		result.Before = append(result.Before, CategorySortOrder{category.DisplayName, category.SortOrder})
+	}
+
+	var updatedCategories []*model.SidebarCategoryWithChannels
+	var order []string
+
+	managedChannels := managedChannelCategories(s.u.channels, structure)
+	managed := managedCategories(s.categories)
+
+	for _, category := range s.orderedCategories() {
+		name := category.DisplayName
+		order = append(order, category.Id)
+
+		switch {
+		case category.Type != model.SidebarCategoryCustom:
+			// Built-in categories only move
+		case managed[name] != category:
+			// User-created categories keep their settings and own channels, managed channels move to their category
+			userChannelIDs := withoutManagedChannels(category.Channels, managedChannels, "")
+			updatedCategories = append(updatedCategories, newSidebarCategory(category, userChannelIDs, int(category.SortOrder)))
+		default:
+			configuredChannelIDs := categoryChannelIDs(s.u.channels, structure, name)
+
+			// Configured channels come first, channels the user added follow in their previous order
+			orderedChannelIDs := appendUnique(configuredChannelIDs, withoutManagedChannels(category.Channels, managedChannels, name)...)
+
+			updatedCategory := newSidebarCategory(category, orderedChannelIDs, int(category.SortOrder))
+			if config.EnforceCategoryPolicies && !s.respectsCategorySettings() {
+				applyCategoryPolicy(updatedCategory)
+			}
+			updatedCategories = append(updatedCategories, updatedCategory)
 		}
+	}
 
-		orderedChannelIDs, err := categoryChannelIDs(s.c, categoryName)
-		if err != nil {
-			return err.Error()
+	if len(updatedCategories) > 0 {
+		if _, appErr := s.c.API.UpdateChannelSidebarCategories(s.User.Id, s.c.Team.Id, updatedCategories); appErr != nil {
+			return nil, apiError("update sidebar categories of user", s.User.Username, appErr)
 		}
+	}
 
-		s.c.API.LogDebug("XXXXX Loop", category.DisplayName, len(orderedChannelIDs), index)
+	if _, err := s.SetCategoryOrder(order); err != nil {
+		return nil, restError("order sidebar categories of user", s.User.Username, err)
+	}
 
-		updatedCategory := newSidebarCategory(category, orderedChannelIDs, 10+10*index)
-		updatedCategories = append(updatedCategories, updatedCategory)
+	if err := s.fetch(); err != nil {
+		return nil, err
 	}
 
-	for _, category := range updatedCategories {
-		dbg = append(dbg, fmt.Sprintf("%s - %d", category.DisplayName, category.SortOrder))
+	for _, category := range s.categories.Categories {
+		result.After = append(result.After, CategorySortOrder{category.DisplayName, category.SortOrder})
 	}
-	dbg = append(dbg, "->>>")
 
-	if _, err := s.c.API.UpdateChannelSidebarCategories(s.User.Id, s.c.Team.Id, updatedCategories); err != nil {
-		return err.Error()
+	return result, nil
+}
+
+// orderedCategories returns all categories of the sidebar in the configured
+// order. Categories are told apart by ID, so that several categories with the
+// same name keep their place.
+func (s *SideBar) orderedCategories() []*model.SidebarCategoryWithChannels {
+	var ids, managedIDs, userCreated []string
+	names := make(map[string]string)
+	byID := make(map[string]*model.SidebarCategoryWithChannels)
+	managed := managedCategories(s.categories)
+
+	for _, category := range s.categories.Categories {
+		ids = append(ids, category.Id)
+		names[category.Id] = category.DisplayName
+		byID[category.Id] = category
+		if category.Type != model.SidebarCategoryCustom {
+			continue
+		}
+		if managed[category.DisplayName] == category {
+			managedIDs = append(managedIDs, category.Id)
+		} else {
+			userCreated = append(userCreated, category.Id)
+		}
 	}
 
-	err := s.fetch()
-	if err != nil {
-		return err.Error()
+	var ordered []*model.SidebarCategoryWithChannels
+	for _, id := range sidebarOrder(ids, names, managedOrder(managedIDs, names), userCreated) {
+		ordered = append(ordered, byID[id])
 	}
+	return ordered
+}
 
-	for _, category := range s.categories.Categories {
-		dbg = append(dbg, fmt.Sprintf("%s - %d", category.DisplayName, category.SortOrder))
+// managedCategories maps the names of the managed categories to the custom
+// category of the sidebar holding them: the first one with that name. Later
+// categories with the same name count as created by the user.
+func managedCategories(sidebar *model.OrderedSidebarCategories) map[string]*model.SidebarCategoryWithChannels {
+	managed := make(map[string]*model.SidebarCategoryWithChannels)
+	for _, category := range sidebar.Categories {
+		if category.Type == model.SidebarCategoryCustom && config.IsManagedCategory(category.DisplayName) && managed[category.DisplayName] == nil {
+			managed[category.DisplayName] = category
+		}
 	}
+	return managed
+}
 
-	return strings.Join(dbg, "\n")
+// managedOrder orders the managed categories, given by key with their names,
+// as config.CategoryOrder says, followed by the archive category. Categories
+// not in that order follow in their given order.
+func managedOrder(keys []string, names map[string]string) []string {
+	var ordered []string
+	for _, name := range append(append([]string{}, config.CategoryOrder...), config.ArchiveCategory) {
+		for _, key := range keys {
+			if names[key] == name {
+				ordered = append(ordered, key)
+			}
+		}
+	}
+	return appendUnique(ordered, keys...)
+}
+
+// sidebarOrder orders the categories, given by key with their names, as
+// config.SidebarOrder places them: the built-in categories by name, the
+// managed and the user-created categories in their given order. Categories
+// not placed follow in their given order.
+func sidebarOrder(keys []string, names map[string]string, managed []string, userCreated []string) []string {
+	var ordered []string
+
+	for _, entry := range config.SidebarOrder {
+		switch entry {
+		case config.ManagedCategories:
+			ordered = appendUnique(ordered, managed...)
+		case config.UserCategories:
+			ordered = appendUnique(ordered, userCreated...)
+		default:
+			for _, key := range keys {
+				if names[key] == entry && !utils.Contains(managed, key) && !utils.Contains(userCreated, key) {
+					ordered = appendUnique(ordered, key)
+				}
+			}
+		}
+	}
+
+	return appendUnique(ordered, keys...)
 }
 
 //func (s *SideBar) ReorderSidebarCategories_OLD() string {
@@ -472,7 +591,7 @@ func newSidebarCategory(m *model.SidebarCategoryWithChannels, orderedChannelIDs
 			UserId:      m.UserId,
 			TeamId:      m.TeamId,
 			SortOrder:   int64(sortOrder), // Set SortOrder based on the sortOrder
-			Sorting:     "manual",
+			Sorting:     m.Sorting,
 			Type:        m.Type,
 			DisplayName: m.DisplayName,
 			Muted:       m.Muted,
@@ -482,29 +601,57 @@ func newSidebarCategory(m *model.SidebarCategoryWithChannels, orderedChannelIDs
 	}
 }
 
-func (s *SideBar) DeleteAllSidebarCategories() string {
+// applyCategoryPolicy sets the configured sorting, collapsed and muted state.
+func applyCategoryPolicy(category *model.SidebarCategoryWithChannels) {
+	policy := config.Policy(category.DisplayName)
 
-	var names = []string{"Deleting... "}
+	category.Sorting = policy.Sorting
+	category.Collapsed = policy.Collapsed
+	category.Muted = policy.Muted
+}
 
-	sidebarCategories, appErr := s.c.API.GetChannelSidebarCategories(s.User.Id, s.c.Team.Id)
-	if appErr != nil {
-		return appErr.DetailedError
+// respectsCategorySettings tells whether the user asked to keep their own
+// category settings. If the preferences cannot be read, they are respected.
+func (s *SideBar) respectsCategorySettings() bool {
+	if s.preferences == nil {
+		preferences, err := LoadPreferences(s.c, s.User.Id)
+		if err != nil {
+			s.c.API.LogWarn("Failed to load preferences", "user_id", s.User.Id, "error", err.Error())
+			return true
+		}
+		s.preferences = preferences
 	}
+	return s.preferences.RespectCategorySettings
+}
+
+// DeleteAllSidebarCategories deletes the managed categories, and the
+// categories created by the user if asked to, and returns the deleted ones.
+func (s *SideBar) DeleteAllSidebarCategories(includeUserCategories bool) ([]string, error) {
+
+	var names []string
+	failed := failures{op: "delete sidebar categories"}
 
-	for _, category := range sidebarCategories.Categories {
+	if err := s.fetch(); err != nil {
+		return nil, err
+	}
+
+	for _, category := range s.categories.Categories {
 
 		if utils.Contains(config.DefaultCategories, category.DisplayName) {
 			continue
 		}
+		if !includeUserCategories && !config.IsManagedCategory(category.DisplayName) {
+			continue
+		}
 
-		names = append(names, category.DisplayName)
-		_, err := s.DeleteCategory(category.Id)
-		if err != nil {
-			names = append(names, fmt.Sprintf("Could not delete **%s** because **%s**\n", category.DisplayName, err.Error()))
+		if _, err := s.DeleteCategory(category.Id); err != nil {
+			failed.add(category.DisplayName, restError("delete sidebar category", category.DisplayName, err))
+			continue
 		}
+		names = append(names, category.DisplayName)
 	}
 
-	return strings.Join(names, ", ")
+	return names, failed.err()
 }
 
 func (s *SideBar) DeleteCategory(categoryID string) ([]byte, error) {
@@ -544,12 +691,28 @@ func (s *SideBar) SetCategoryOrder(categoryIDsOrdered []string) ([]byte, error)
 //	return strings.Join(answer, "\n")
 //}
 
-func (s *SideBar) CheckChannelStructure() string {
+// CheckChannelStructure compares the sidebar and channels of the user with
+// the structure.
+func (s *SideBar) CheckChannelStructure() (*StructureReport, error) {
+
+	report := &StructureReport{User: s.User}
+
+	structure, err := s.u.Structure()
+	if err != nil {
+		return nil, err
+	}
+
+	report.MissingCategories = s.checkSidebarCategories(structure)
+
+	report.MissingChannels, report.UnresolvedChannels, err = s.u.checkChannelSubscription(structure)
+	if err != nil {
+		return nil, err
+	}
+
+	report.WronglyCategorized, err = s.checkChannelCategorization(structure)
+	if err != nil {
+		return nil, err
+	}
 
-	return fmt.Sprintf("User: **%s** (%s):\n%s\n%s\n%s\n",
-		s.User.Username,
-		s.User.GetFullName(),
-		s.checkSidebarCategories(),
-		s.u.checkChannelSubscription(),
-		s.checkChannelCategorization())
+	return report, nil
 }
@@ -0,0 +1,281 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"reflect"
+	"testing"
+)
+
+func TestCheckAndJoinDefaultChannelStructure(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+
+	// Onboarding twice must leave the same sidebar as onboarding once
+	for run := 1; run <= 2; run++ {
+		result := f.onboard(t, user)
+
+		if run == 2 && len(result.Join.Joined) != 0 {
+			t.Errorf("run 2: joined %v again", result.Join.Joined)
+		}
+		if posts := f.api.Posts(f.channels["club-news"].Id); len(posts) != 1 || posts[0].Type != model.PostTypeAddToChannel || posts[0].UserId != f.c.BotID {
+			t.Errorf("run %d: membership messages %v, want one by the bot", run, posts)
+		}
+
+		for _, category := range config.PublicStructure() {
+			for _, entry := range category.Channels {
+				if !f.api.IsMember(f.channels[entry.Name].Id, user.Id) {
+					t.Errorf("run %d: not a member of %s", run, entry.Name)
+				}
+			}
+		}
+
+		if names := f.categoryNames(user); len(names) != len(config.CategoryOrder)+3 {
+			t.Errorf("run %d: categories = %v, want the %d managed ones and the system ones", run, names, len(config.CategoryOrder))
+		}
+
+		for _, categoryName := range config.CategoryOrder {
+			category := f.category(user, categoryName)
+			if !reflect.DeepEqual(category.Channels, f.configuredChannelIDs(categoryName)) {
+				t.Errorf("run %d: channels of %s = %v, want %v", run, categoryName, category.Channels, f.configuredChannelIDs(categoryName))
+			}
+
+			policy := config.Policy(categoryName)
+			if category.Sorting != policy.Sorting || category.Collapsed != policy.Collapsed || category.Muted != policy.Muted {
+				t.Errorf("run %d: settings of %s = %s/%t/%t, want %+v", run, categoryName, category.Sorting, category.Collapsed, category.Muted, policy)
+			}
+		}
+	}
+}
+
+func TestOnboardingKeepsCategorySettings(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.onboard(t, user)
+
+	racing := f.category(user, "Racing")
+	racing.Collapsed = !racing.Collapsed
+	racing.Sorting = model.SidebarCategorySortRecent
+	updateCategory(f, user, racing)
+
+	f.onboard(t, user)
+
+	if category := f.category(user, "Racing"); category.Collapsed != racing.Collapsed || category.Sorting != model.SidebarCategorySortRecent {
+		t.Errorf("settings of Racing = %s/%t, want the choice of the user", category.Sorting, category.Collapsed)
+	}
+}
+
+func TestOnboardingKeepsUserChannels(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.onboard(t, user)
+
+	extra := f.api.AddChannel(f.team.Id, "regatta-photos", "Regatta Photos", model.ChannelTypeOpen)
+	f.api.AddMember(extra.Id, user.Id)
+	racing := f.category(user, "Racing")
+	racing.Channels = append(racing.Channels, extra.Id)
+	updateCategory(f, user, racing)
+
+	f.onboard(t, user)
+
+	expected := append(f.configuredChannelIDs("Racing"), extra.Id)
+	if channels := f.category(user, "Racing").Channels; !reflect.DeepEqual(channels, expected) {
+		t.Errorf("channels of Racing = %v, want %v", channels, expected)
+	}
+}
+
+func TestReorderSidebarCategories(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare changes the onboarded sidebar and returns a check of the reordered one
+		prepare func(f *fixture, user *model.User) func(t *testing.T)
+	}{
+		{
+			name: "keeps user category and its channels",
+			prepare: func(f *fixture, user *model.User) func(t *testing.T) {
+				extra := f.api.AddChannel(f.team.Id, "regatta-photos", "Regatta Photos", model.ChannelTypeOpen)
+				f.api.AddMember(extra.Id, user.Id)
+				mine := createCategory(f, user, "Mine")
+				mine.Channels = []string{extra.Id}
+				updateCategory(f, user, mine)
+
+				return func(t *testing.T) {
+					category := f.category(user, "Mine")
+					if category == nil || category.Id != mine.Id {
+						t.Fatalf("user category was replaced")
+					}
+					if !reflect.DeepEqual(category.Channels, []string{extra.Id}) {
+						t.Errorf("channels of Mine = %v, want %v", category.Channels, []string{extra.Id})
+					}
+				}
+			},
+		},
+		{
+			name: "keeps user channel at the end of a managed category",
+			prepare: func(f *fixture, user *model.User) func(t *testing.T) {
+				extra := f.api.AddChannel(f.team.Id, "regatta-photos", "Regatta Photos", model.ChannelTypeOpen)
+				f.api.AddMember(extra.Id, user.Id)
+				racing := f.category(user, "Racing")
+				racing.Channels = append([]string{extra.Id}, racing.Channels...)
+				updateCategory(f, user, racing)
+
+				return func(t *testing.T) {
+					expected := append(f.configuredChannelIDs("Racing"), extra.Id)
+					if channels := f.category(user, "Racing").Channels; !reflect.DeepEqual(channels, expected) {
+						t.Errorf("channels of Racing = %v, want %v", channels, expected)
+					}
+				}
+			},
+		},
+		{
+			name: "moves managed channel out of user category",
+			prepare: func(f *fixture, user *model.User) func(t *testing.T) {
+				kaagCup := f.channels["kaag-cup"].Id
+				mine := createCategory(f, user, "Mine")
+				mine.Channels = []string{kaagCup}
+				updateCategory(f, user, mine)
+
+				return func(t *testing.T) {
+					if channels := f.category(user, "Mine").Channels; len(channels) != 0 {
+						t.Errorf("channels of Mine = %v, want none", channels)
+					}
+					if channels := f.category(user, "Racing").Channels; !reflect.DeepEqual(channels, f.configuredChannelIDs("Racing")) {
+						t.Errorf("channels of Racing = %v, want %v", channels, f.configuredChannelIDs("Racing"))
+					}
+				}
+			},
+		},
+		{
+			name: "keeps user categories with the same name",
+			prepare: func(f *fixture, user *model.User) func(t *testing.T) {
+				first := createCategory(f, user, "Mine")
+				second := createCategory(f, user, "Mine")
+
+				return func(t *testing.T) {
+					var ids []string
+					for _, category := range f.api.Categories(user.Id, f.team.Id) {
+						if category.DisplayName == "Mine" {
+							ids = append(ids, category.Id)
+						}
+					}
+					if !reflect.DeepEqual(ids, []string{second.Id, first.Id}) {
+						t.Errorf("categories named Mine = %v, want both in their previous order", ids)
+					}
+				}
+			},
+		},
+		{
+			name: "keeps structure channel not meant for the user in user category",
+			prepare: func(f *fixture, user *model.User) func(t *testing.T) {
+				committee := f.channels["committee"].Id
+				f.api.AddMember(committee, user.Id)
+				mine := createCategory(f, user, "Mine")
+				mine.Channels = []string{committee}
+				updateCategory(f, user, mine)
+
+				return func(t *testing.T) {
+					if channels := f.category(user, "Mine").Channels; !reflect.DeepEqual(channels, []string{committee}) {
+						t.Errorf("channels of Mine = %v, want %v", channels, []string{committee})
+					}
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFixture(t)
+			user := f.addUser("skipper")
+			f.onboard(t, user)
+
+			check := test.prepare(f, user)
+			if _, err := f.sideBar(t, user).ReorderSidebarCategories(); err != nil {
+				t.Fatalf("ReorderSidebarCategories: %v", err)
+			}
+			check(t)
+		})
+	}
+}
+
+func TestDeleteAllSidebarCategories(t *testing.T) {
+	tests := []struct {
+		name                  string
+		includeUserCategories bool
+		expected              []string
+	}{
+		{"managed only", false, []string{"Favorites", "Mine", "Channels", "Direct Messages"}},
+		{"including user categories", true, []string{"Favorites", "Channels", "Direct Messages"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFixture(t)
+			user := f.addUser("skipper")
+			f.onboard(t, user)
+			createCategory(f, user, "Mine")
+
+			if _, err := f.sideBar(t, user).DeleteAllSidebarCategories(test.includeUserCategories); err != nil {
+				t.Fatalf("DeleteAllSidebarCategories: %v", err)
+			}
+
+			if names := f.categoryNames(user); !reflect.DeepEqual(names, test.expected) {
+				t.Errorf("categories = %v, want %v", names, test.expected)
+			}
+			if channels := f.category(user, "Channels").Channels; !containsAll(channels, f.configuredChannelIDs("Racing")) {
+				t.Errorf("channels of deleted categories did not return to Channels")
+			}
+		})
+	}
+}
+
+func createCategory(f *fixture, user *model.User, name string) *model.SidebarCategoryWithChannels {
+	category, appErr := f.api.CreateChannelSidebarCategory(user.Id, f.team.Id, &model.SidebarCategoryWithChannels{
+		SidebarCategory: model.SidebarCategory{DisplayName: name},
+	})
+	if appErr != nil {
+		panic(appErr)
+	}
+	return category
+}
+
+func updateCategory(f *fixture, user *model.User, category *model.SidebarCategoryWithChannels) {
+	if _, appErr := f.api.UpdateChannelSidebarCategories(user.Id, f.team.Id, []*model.SidebarCategoryWithChannels{category}); appErr != nil {
+		panic(appErr)
+	}
+}
+
+func containsAll(channelIDs []string, expected []string) bool {
+	present := make(map[string]bool)
+	for _, channelID := range channelIDs {
+		present[channelID] = true
+	}
+	for _, channelID := range expected {
+		if !present[channelID] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReorderPlacesBuiltInCategories(t *testing.T) {
+	defer func(order []string) { config.SidebarOrder = order }(config.SidebarOrder)
+	config.SidebarOrder = []string{"Channels", config.UserCategories, config.ManagedCategories, "Favorites"}
+
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.onboard(t, user)
+	createCategory(f, user, "Mine")
+
+	if _, err := f.sideBar(t, user).ReorderSidebarCategories(); err != nil {
+		t.Fatalf("ReorderSidebarCategories: %v", err)
+	}
+
+	// Direct Messages is not placed and follows in its previous order
+	expected := append(append([]string{"Channels", "Mine"}, config.CategoryOrder...), "Favorites", "Direct Messages")
+	if names := f.categoryNames(user); !reflect.DeepEqual(names, expected) {
+		t.Errorf("categories = %v, want %v", names, expected)
+	}
+	if calls := f.api.Calls("REST PUT"); calls == 0 {
+		t.Errorf("the order endpoint was not used")
+	}
+}
@@ -0,0 +1,189 @@
+package business
+
+import (
+	"errors"
+	"fmt"
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/utils"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found in the structure definition, with a
+// suggestion how to fix it.
+type Diagnostic struct {
+	Severity Severity
+	Subject  string
+	Message  string
+	Fix      string
+}
+
+// ValidateStructure checks the structure definition on its own and against
+// the channels of the team.
+func (t *Team) ValidateStructure() []Diagnostic {
+	reserved := append(append([]string{}, config.DefaultCategories...), config.ArchiveCategory)
+	diagnostics := validateDefinition(config.Structure, config.CategoryOrder, reserved)
+	diagnostics = append(diagnostics, validateSidebarOrder(config.SidebarOrder, config.DefaultCategories)...)
+	return append(diagnostics, t.validateChannels(config.Structure)...)
+}
+
+// validateSidebarOrder checks that SidebarOrder places known built-in
+// categories and the slots once each.
+func validateSidebarOrder(order []string, builtIn []string) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	seen := make(map[string]bool)
+	for _, entry := range order {
+		if seen[entry] {
+			diagnostics = append(diagnostics, Diagnostic{SeverityWarning, entry,
+				"entry is listed twice in SidebarOrder", "remove the second entry"})
+		}
+		seen[entry] = true
+
+		if entry != config.ManagedCategories && entry != config.UserCategories && !utils.Contains(builtIn, entry) {
+			diagnostics = append(diagnostics, Diagnostic{SeverityError, entry,
+				"SidebarOrder lists an unknown built-in category", fmt.Sprintf("use one of %s or a slot", strings.Join(builtIn, ", "))})
+		}
+	}
+	if !seen[config.ManagedCategories] {
+		diagnostics = append(diagnostics, Diagnostic{SeverityWarning, config.ManagedCategories,
+			"SidebarOrder has no slot for the managed categories, they follow the placed ones", "add config.ManagedCategories to SidebarOrder"})
+	}
+
+	return diagnostics
+}
+
+// validateDefinition finds duplicates, unknown or unordered categories and
+// reserved names, without looking at the server.
+func validateDefinition(structure []config.Category, order []string, reserved []string) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	categories := make(map[string]bool)
+	channels := make(map[string]string) // channel key -> category
+
+	for _, category := range structure {
+		if categories[category.Name] {
+			diagnostics = append(diagnostics, Diagnostic{SeverityError, category.Name,
+				"category is defined twice", "merge both definitions into one"})
+		}
+		categories[category.Name] = true
+
+		if utils.Contains(reserved, category.Name) {
+			diagnostics = append(diagnostics, Diagnostic{SeverityError, category.Name,
+				"category name is reserved for a built-in category", "rename the category"})
+		}
+		if !utils.Contains(order, category.Name) {
+			diagnostics = append(diagnostics, Diagnostic{SeverityWarning, category.Name,
+				"category is missing from CategoryOrder and will not be ordered", fmt.Sprintf("add %q to CategoryOrder", category.Name)})
+		}
+		if !isValidWindow(category.Active) {
+			diagnostics = append(diagnostics, Diagnostic{SeverityError, category.Name,
+				"category becomes active after it ended", "correct the dates of its window"})
+		}
+		if !isValidSorting(category.Policy.Sorting) {
+			diagnostics = append(diagnostics, Diagnostic{SeverityError, category.Name,
+				fmt.Sprintf("unknown sorting %q", category.Policy.Sorting), "use manual, alpha or recent sorting"})
+		}
+
+		for _, channel := range category.Channels {
+			key := channelKey(channel)
+			if previous, exists := channels[key]; exists {
+				diagnostics = append(diagnostics, Diagnostic{SeverityError, channel.String(),
+					fmt.Sprintf("channel is listed in %s and in %s", previous, category.Name), "keep the channel in one category only"})
+				continue
+			}
+			channels[key] = category.Name
+
+			if !isValidWindow(channel.Active) {
+				diagnostics = append(diagnostics, Diagnostic{SeverityError, channel.String(),
+					"channel becomes active after it ended", "correct the dates of its window"})
+			}
+			if channel.Name == "" && channel.ID == "" {
+				diagnostics = append(diagnostics, Diagnostic{SeverityWarning, channel.String(),
+					"channel has neither a name nor an ID and is matched by display name", "set the URL name of the channel"})
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, categoryName := range order {
+		if seen[categoryName] {
+			diagnostics = append(diagnostics, Diagnostic{SeverityWarning, categoryName,
+				"category is listed twice in CategoryOrder", "remove the second entry"})
+		}
+		seen[categoryName] = true
+
+		if !categories[categoryName] {
+			diagnostics = append(diagnostics, Diagnostic{SeverityError, categoryName,
+				"CategoryOrder lists an unknown category", "define the category in Structure or remove it from CategoryOrder"})
+		}
+	}
+
+	return diagnostics
+}
+
+// validateChannels checks that every channel of the structure exists in the
+// team with the configured type.
+func (t *Team) validateChannels(structure []config.Category) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	for _, category := range structure {
+		for _, entry := range category.Channels {
+			if config.ArchiveEndedChannels && (category.Active.Ended(now()) || entry.Active.Ended(now())) {
+				// Ended channels are archived and cannot be resolved
+				continue
+			}
+			channel, err := t.channels.Resolve(entry)
+
+			switch {
+			case errors.Is(err, ErrChannelAmbiguous):
+				diagnostics = append(diagnostics, Diagnostic{SeverityError, entry.String(),
+					err.Error(), "set the URL name or the ID of the channel"})
+			case err != nil:
+				fix := "create the channel or correct its name"
+				if !entry.Private {
+					fix = "run /anchor create_channels or correct its name"
+				}
+				diagnostics = append(diagnostics, Diagnostic{SeverityError, entry.String(), err.Error(), fix})
+			case entry.Private && channel.Type != model.ChannelTypePrivate:
+				diagnostics = append(diagnostics, Diagnostic{SeverityWarning, entry.String(),
+					"channel is configured as private but is public", "convert the channel or drop the private flag"})
+			case !entry.Private && channel.Type != model.ChannelTypeOpen:
+				diagnostics = append(diagnostics, Diagnostic{SeverityWarning, entry.String(),
+					"channel is configured as public but is private", "convert the channel or set the private flag"})
+			}
+		}
+	}
+
+	return diagnostics
+}
+
+func channelKey(channel config.Channel) string {
+	switch {
+	case channel.ID != "":
+		return "id:" + channel.ID
+	case channel.Name != "":
+		return "name:" + channel.Name
+	default:
+		return "display:" + strings.ToLower(channel.DisplayName)
+	}
+}
+
+func isValidWindow(window config.Window) bool {
+	return window.From.IsZero() || window.Until.IsZero() || window.From.Before(window.Until)
+}
+
+func isValidSorting(sorting model.SidebarCategorySorting) bool {
+	switch sorting {
+	case model.SidebarCategorySortDefault, model.SidebarCategorySortManual, model.SidebarCategorySortAlphabetical, model.SidebarCategorySortRecent:
+		return true
+	}
+	return false
+}
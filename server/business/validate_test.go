@@ -0,0 +1,94 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidateDefinition(t *testing.T) {
+	racing := config.Category{Name: "Racing", Channels: []config.Channel{{DisplayName: "Kaag Cup", Name: "kaag-cup"}}}
+	fleet := config.Category{Name: "Fleet", Channels: []config.Channel{{DisplayName: "Fox", Name: "fox"}}}
+
+	tests := []struct {
+		name      string
+		structure []config.Category
+		order     []string
+		subjects  []string
+	}{
+		{"valid", []config.Category{racing, fleet}, []string{"Racing", "Fleet"}, nil},
+		{"category missing from order", []config.Category{racing, fleet}, []string{"Racing"}, []string{"Fleet"}},
+		{"unknown category in order", []config.Category{racing}, []string{"Racing", "Cruising"}, []string{"Cruising"}},
+		{"duplicate category", []config.Category{racing, racing}, []string{"Racing"}, []string{"Racing", "Kaag Cup"}},
+		{"channel in two categories", []config.Category{racing, {Name: "Fleet", Channels: racing.Channels}}, []string{"Racing", "Fleet"}, []string{"Kaag Cup"}},
+		{"reserved name", []config.Category{{Name: "Favorites"}}, []string{"Favorites"}, []string{"Favorites"}},
+		{"display name only", []config.Category{{Name: "Racing", Channels: []config.Channel{{DisplayName: "Kaag Cup"}}}}, []string{"Racing"}, []string{"Kaag Cup"}},
+		{"window ends before it starts", []config.Category{{Name: "Racing", Channels: []config.Channel{{DisplayName: "Kaag Cup", Name: "kaag-cup",
+			Active: config.Window{From: time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC), Until: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)}}}}}, []string{"Racing"}, []string{"Kaag Cup"}},
+		{"unknown sorting", []config.Category{{Name: "Racing", Policy: config.CategoryPolicy{Sorting: "random"}}}, []string{"Racing"}, []string{"Racing"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var subjects []string
+			for _, diagnostic := range validateDefinition(test.structure, test.order, config.DefaultCategories) {
+				subjects = append(subjects, diagnostic.Subject)
+			}
+			if !reflect.DeepEqual(subjects, test.subjects) {
+				t.Errorf("diagnostics for %v, want %v", subjects, test.subjects)
+			}
+		})
+	}
+}
+
+func TestValidateChannels(t *testing.T) {
+	f := newFixture(t)
+	team := WrapTeam(f.c, f.team)
+
+	if diagnostics := team.ValidateStructure(); len(diagnostics) != 0 {
+		t.Fatalf("diagnostics = %+v, want none", diagnostics)
+	}
+
+	f.api.AddChannel(f.team.Id, "cruising-2", "Cruising", model.ChannelTypeOpen)
+	structure := []config.Category{{Name: "Cruising", Channels: []config.Channel{
+		{DisplayName: "Cruising"},
+		{DisplayName: "Committee", Name: "committee"},
+		{DisplayName: "Sailing School", Name: "sailing-school"},
+	}}}
+
+	var severities []Severity
+	for _, diagnostic := range WrapTeam(f.c, f.team).validateChannels(structure) {
+		severities = append(severities, diagnostic.Severity)
+	}
+	expected := []Severity{SeverityError, SeverityWarning, SeverityError}
+	if !reflect.DeepEqual(severities, expected) {
+		t.Errorf("severities = %v, want %v (ambiguous, wrong type, missing)", severities, expected)
+	}
+}
+
+func TestValidateSidebarOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    []string
+		subjects []string
+	}{
+		{"valid", config.SidebarOrder, nil},
+		{"unknown built-in category", []string{"Favourites", config.ManagedCategories}, []string{"Favourites"}},
+		{"listed twice", []string{config.ManagedCategories, "Channels", "Channels"}, []string{"Channels"}},
+		{"no managed slot", []string{"Favorites", config.UserCategories}, []string{config.ManagedCategories}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var subjects []string
+			for _, diagnostic := range validateSidebarOrder(test.order, config.DefaultCategories) {
+				subjects = append(subjects, diagnostic.Subject)
+			}
+			if !reflect.DeepEqual(subjects, test.subjects) {
+				t.Errorf("diagnostics for %v, want %v", subjects, test.subjects)
+			}
+		})
+	}
+}
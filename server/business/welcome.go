@@ -0,0 +1,118 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/utils"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"strings"
+	"text/template"
+)
+
+var errNoBot = errors.New("the bot of the plugin is not registered")
+
+// WelcomeData is what config.WelcomeTemplate is rendered with.
+type WelcomeData struct {
+	Team        string
+	Name        string
+	Categories  []WelcomeCategory
+	KeyChannels []string // URL names
+}
+
+type WelcomeCategory struct {
+	Name        string
+	Description string
+	Channels    []WelcomeChannel
+}
+
+type WelcomeChannel struct {
+	Name        string // URL name, for linking with ~name
+	DisplayName string
+	Description string
+}
+
+// members
+
+// SendWelcome sends the user a direct message from the bot introducing the
+// channels they joined, grouped by category, and linking the key channels.
+func (s *SideBar) SendWelcome(joined []string) (*model.Post, error) {
+	if s.c.BotID == "" {
+		return nil, NewError(KindInvalid, "send welcome to", s.User.Username, errNoBot)
+	}
+
+	data, err := s.welcomeData(joined)
+	if err != nil {
+		return nil, err
+	}
+	message, err := renderWelcome(config.WelcomeTemplate, data)
+	if err != nil {
+		return nil, err
+	}
+
+	channel, appErr := s.c.API.GetDirectChannel(s.c.BotID, s.User.Id)
+	if appErr != nil {
+		return nil, apiError("open direct channel with", s.User.Username, appErr)
+	}
+
+	post, appErr := s.c.API.CreatePost(&model.Post{
+		UserId:    s.c.BotID,
+		ChannelId: channel.Id,
+		Message:   message,
+	})
+	if appErr != nil {
+		return nil, apiError("send welcome to", s.User.Username, appErr)
+	}
+	return post, nil
+}
+
+// private
+
+func (s *SideBar) welcomeData(joined []string) (*WelcomeData, error) {
+	data := &WelcomeData{Team: s.c.Team.DisplayName, Name: s.User.GetDisplayName(model.ShowNicknameFullName)}
+
+	structure, err := s.u.Structure()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, category := range structure {
+		welcomeCategory := WelcomeCategory{Name: category.Name, Description: category.Description}
+		for _, entry := range category.Channels {
+			if !utils.Contains(joined, entry.String()) {
+				continue
+			}
+			channel, err := s.u.channels.Resolve(entry)
+			if err != nil {
+				continue
+			}
+			welcomeCategory.Channels = append(welcomeCategory.Channels, WelcomeChannel{channel.Name, channel.DisplayName, entry.Description})
+		}
+		if len(welcomeCategory.Channels) > 0 {
+			data.Categories = append(data.Categories, welcomeCategory)
+		}
+	}
+
+	for _, name := range config.KeyChannels {
+		channel, err := s.u.channels.Resolve(config.Channel{Name: name})
+		if err != nil {
+			continue
+		}
+		data.KeyChannels = append(data.KeyChannels, channel.Name)
+	}
+
+	return data, nil
+}
+
+func renderWelcome(text string, data *WelcomeData) (string, error) {
+	welcome, err := template.New("welcome").Parse(text)
+	if err != nil {
+		return "", NewError(KindInvalid, "parse welcome template", "", err)
+	}
+
+	var message bytes.Buffer
+	if err := welcome.Execute(&message, data); err != nil {
+		return "", NewError(KindInvalid, "render welcome template", "", err)
+	}
+	return strings.TrimSpace(message.String()), nil
+}
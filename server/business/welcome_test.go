@@ -0,0 +1,92 @@
+package business
+
+import (
+	"github.com/glass.plugin-anchor/server/config"
+	"strings"
+	"testing"
+)
+
+func TestOnboardingSendsWelcome(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+
+	result := f.onboard(t, user)
+	if result.Welcome == nil {
+		t.Fatal("no welcome message sent")
+	}
+
+	channel, appErr := f.api.GetDirectChannel(f.c.BotID, user.Id)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if result.Welcome.ChannelId != channel.Id || result.Welcome.UserId != f.c.BotID {
+		t.Errorf("welcome posted by %s in %s, want a direct message from the bot", result.Welcome.UserId, result.Welcome.ChannelId)
+	}
+
+	message := result.Welcome.Message
+	for _, expected := range []string{
+		"**Club Life**",
+		"~crew-finder: " + channelDescription(t, "crew-finder"),
+		"Start with ~club-news, ~crew-finder.",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("welcome lacks %q:\n%s", expected, message)
+		}
+	}
+	if strings.Contains(message, "~town-square") {
+		t.Errorf("welcome lists a channel the user was in before:\n%s", message)
+	}
+
+	// A second onboarding joins nothing and sends nothing
+	f.api.ResetCalls()
+	if result := f.onboard(t, user); result.Welcome != nil || f.api.Calls("CreatePost") != 0 {
+		t.Errorf("welcome sent again")
+	}
+}
+
+func TestWelcomeNeedsBot(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser("skipper")
+	f.c.BotID = ""
+
+	_, err := f.sideBar(t, user).SendWelcome([]string{"Club News"})
+	if KindOf(err) != KindInvalid {
+		t.Errorf("err = %v, want %s", err, KindInvalid)
+	}
+}
+
+func TestRenderWelcome(t *testing.T) {
+	message, err := renderWelcome(config.WelcomeTemplate, &WelcomeData{
+		Team: "ESC",
+		Name: "Skipper",
+		Categories: []WelcomeCategory{{Name: "Racing", Channels: []WelcomeChannel{
+			{Name: "laser", DisplayName: "Laser"},
+		}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Welcome to **ESC**, Skipper!\n\nYou were added to these channels:\n\n**Racing**\n- ~laser"
+	if message != expected {
+		t.Errorf("message = %q, want %q", message, expected)
+	}
+
+	if _, err := renderWelcome("{{.Crew}", &WelcomeData{}); KindOf(err) != KindInvalid {
+		t.Errorf("err = %v for a broken template, want %s", err, KindInvalid)
+	}
+}
+
+func channelDescription(tb testing.TB, name string) string {
+	tb.Helper()
+
+	for _, category := range config.Structure {
+		for _, entry := range category.Channels {
+			if entry.Name == name {
+				return entry.Description
+			}
+		}
+	}
+	tb.Fatalf("no channel %s in the structure", name)
+	return ""
+}
@@ -6,8 +6,10 @@ import (
 	"github.com/glass.plugin-anchor/server/business"
 	"github.com/glass.plugin-anchor/server/config"
 	"github.com/glass.plugin-anchor/server/models"
+	"github.com/glass.plugin-anchor/server/utils"
 	"github.com/mattermost/mattermost-server/v6/model"
 	"github.com/mattermost/mattermost-server/v6/plugin"
+	"strconv"
 	"strings"
 )
 
@@ -22,12 +24,26 @@ func (p *AnchorPlugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs
 		response = p.GetCommandResponse(c, args.Command)
 	}
 
-	return &model.CommandResponse{
-		ResponseType: model.CommandResponseTypeEphemeral,
-		Text:         response,
-	}, nil
+	// The bot answers, unless it could not be ensured on activation
+	if p.botID == "" {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			Text:         response,
+		}, nil
+	}
+
+	p.API.SendEphemeralPost(args.UserId, &model.Post{
+		UserId:    p.botID,
+		ChannelId: args.ChannelId,
+		Message:   response,
+	})
+	return &model.CommandResponse{}, nil
 }
 
+// errNotAdmin is the permission error of a command run by a user who is not
+// a system admin.
+var errNotAdmin = errors.New("not a system admin")
+
 func checkCommand(c *models.Context, line string) error {
 	arguments := strings.Fields(line)
 
@@ -35,7 +51,7 @@ func checkCommand(c *models.Context, line string) error {
 		return errors.New("invalid command: " + arguments[0])
 	}
 	if !c.User.IsSystemAdmin() {
-		return errors.New("You do not have permission to execute this command.")
+		return business.NewError(business.KindPermission, "execute command", arguments[0], errNotAdmin)
 	}
 	if arguments[0] == "/q" {
 		return nil
@@ -47,6 +63,15 @@ func checkCommand(c *models.Context, line string) error {
 	return nil
 }
 
+// optionCommands take options instead of a user name.
+var optionCommands = []string{"cleanup_team", "roster", "activity"}
+
+// allTeams tells whether the command applies to all teams of the user.
+func allTeams(line string) bool {
+	arguments := strings.Fields(line)
+	return len(arguments) > 3 && arguments[3] == "--all-teams"
+}
+
 func parseCommand(c *models.Context, line string) (string, *business.Team, *business.User, *business.SideBar, error) {
 
 	var err error
@@ -66,7 +91,7 @@ func parseCommand(c *models.Context, line string) (string, *business.Team, *busi
 
 	case "/q":
 
-		user, err = business.NewUser(c, "boris")
+		user, err = team.NewUser("boris")
 		if err != nil {
 			return "", nil, nil, nil, err
 		}
@@ -80,8 +105,8 @@ func parseCommand(c *models.Context, line string) (string, *business.Team, *busi
 
 		command = arguments[1]
 
-		if len(arguments) > 2 {
-			user, err = business.NewUser(c, arguments[2])
+		if len(arguments) > 2 && !utils.Contains(optionCommands, command) {
+			user, err = team.NewUser(arguments[2])
 			if err != nil {
 				return "", nil, nil, nil, err
 			}
@@ -104,16 +129,15 @@ func (p *AnchorPlugin) GetCommandResponse(_ *plugin.Context, commandLine string)
 
 	command, team, user, sideBar, err := parseCommand(p.Context, commandLine)
 	if err != nil {
-		return err.Error()
+		return renderError(err)
 	}
 
 	switch command {
 
 	case "hello":
 		version, err := p.GetVersion()
-
 		if err != nil {
-
+			return renderError(err)
 		}
 
 		return fmt.Sprintf("Hello %s, this is anchor plugin version %s.",
@@ -121,38 +145,247 @@ func (p *AnchorPlugin) GetCommandResponse(_ *plugin.Context, commandLine string)
 		)
 
 	case "users":
-		return business.GetUserListString(c)
+		users, err := business.ListUsers(c)
+		if err != nil {
+			return renderError(err)
+		}
+		return renderUsers(users)
 
 	case "cleanup":
-		return business.CleanPosts(c, c.Channel.Id, true)
+		result, err := business.CleanPosts(c, c.Channel.Id, true)
+		if err != nil && result == nil {
+			return renderError(err)
+		}
+		return withError(renderCleanup(result), err)
+
+	case "cleanup_team":
+		arguments := strings.Fields(commandLine)
+		allChannels := utils.Contains(arguments[2:], "all")
+		dryRun := !utils.Contains(arguments[2:], "apply")
+
+		started := p.runJob("cleanup_"+team.Id, c, func(c *models.Context) string {
+			result, err := business.WrapTeam(c, c.Team).CleanChannels(allChannels, dryRun)
+			if err != nil && result == nil {
+				return renderError(err)
+			}
+			return withError(renderTeamCleanup(c.Team.DisplayName, result), err)
+		})
+		if !started {
+			return "A cleanup of the team is running already."
+		}
+		return "Started the cleanup of the team, you will get a report when it is done."
+
+	case "cleanup_queued":
+		result, err := business.CleanQueuedPosts(c, false)
+		if err != nil && result == nil {
+			return renderError(err)
+		}
+		return withError(renderCleanup(result), err)
+
+	case "roster":
+		arguments := strings.Fields(commandLine)
+		if len(arguments) < 3 || arguments[2] != "import" {
+			return "Usage: /anchor roster import [apply] [remove]"
+		}
+		file, data, err := business.FindRoster(c, c.Channel.Id, c.User.Id)
+		if err != nil {
+			return renderError(err)
+		}
+		entries, err := business.ParseRoster(data)
+		if err != nil {
+			return renderError(err)
+		}
+		plan, err := team.PlanRoster(file, entries)
+		if err != nil {
+			return renderError(err)
+		}
+		remove := utils.Contains(arguments[3:], "remove")
+		if !utils.Contains(arguments[3:], "apply") {
+			return renderRosterPlan(plan, false, remove)
+		}
+		return withError(renderRosterPlan(plan, true, remove), team.ApplyRoster(plan, remove))
 
 	case "teams":
-		return business.GetTeamsListString(c)
+		teams, err := business.ListTeams(c)
+		if err != nil {
+			return renderError(err)
+		}
+		return renderTeams(teams)
 
 	case "channels":
-		return team.GetChannelsListString()
+		channels, err := team.PublicChannels()
+		if err != nil {
+			return renderError(err)
+		}
+		return renderChannels(channels)
 
 	case "check":
-		if user != nil {
-			return sideBar.CheckChannelStructure()
+		if user != nil && allTeams(commandLine) {
+			reports, err := business.CheckAllTeams(c, user.User)
+			if err != nil && reports == nil {
+				return renderError(err)
+			}
+			return renderTeamStructureReports(reports)
+		} else if user != nil {
+			report, err := sideBar.CheckChannelStructure()
+			if err != nil {
+				return renderError(err)
+			}
+			return renderStructureReport(report)
 		} else {
-			return team.CheckUserChannelStructure()
+			reports, err := team.CheckUserChannelStructure()
+			if err != nil && reports == nil {
+				return renderError(err)
+			}
+			return withError(renderStructureReports(reports), err)
+		}
+
+	case "activity":
+		days := config.ActivityDays
+		arguments := strings.Fields(commandLine)
+		if len(arguments) > 2 {
+			var err error
+			if len(arguments) != 4 || arguments[2] != "--days" {
+				return "Usage: /anchor activity [--days N]"
+			}
+			if days, err = strconv.Atoi(arguments[3]); err != nil || days < 1 {
+				return "Usage: /anchor activity [--days N]"
+			}
+		}
+		activities, err := team.ChannelActivity(days)
+		if err != nil && activities == nil {
+			return renderError(err)
+		}
+		return withError(renderActivity(days, activities), err)
+
+	case "stats":
+		stats, err := team.ComplianceStats()
+		if err != nil {
+			return renderError(err)
 		}
+		return renderStats(stats)
 
 	case "onboard":
 		if user == nil {
 			return "Missing user name"
 		}
-		return sideBar.CheckAndJoinDefaultChannelStructure()
+		if allTeams(commandLine) {
+			outcomes, err := business.OnboardAllTeams(c, user.User)
+			if err != nil && outcomes == nil {
+				return renderError(err)
+			}
+			return renderTeamOnboardings(outcomes)
+		}
+		result, err := sideBar.CheckAndJoinDefaultChannelStructure()
+		if err != nil && result == nil {
+			return renderError(err)
+		}
+		return withError(renderOnboarding(result), err)
+
+	case "validate":
+		return renderDiagnostics(team.ValidateStructure())
 
 	case "create_channels":
-		return team.CreateDefaultChannels()
+		created, err := team.CreateDefaultChannels()
+		return withError(renderNames(created, "No channels created"), err)
+
+	case "archive":
+		archived, err := team.ArchiveEndedChannels()
+		if err != nil && archived == nil {
+			return renderError(err)
+		}
+		return withError(renderArchived(archived), err)
+
+	case "season":
+		started := p.runJob("season_"+team.Id, c, func(c *models.Context) string {
+			result, err := business.WrapTeam(c, c.Team).ReconcileSeason()
+			if err != nil && result == nil {
+				return renderError(err)
+			}
+			return withError(renderSeason(result), err)
+		})
+		if !started {
+			return "The season of the team is being reconciled already."
+		}
+		return "Started reconciling the season of the team, you will get a report when it is done."
 
 	case "delete_sidebar":
 		if user == nil {
 			return "Missing user name"
 		}
-		return sideBar.DeleteAllSidebarCategories()
+		arguments := strings.Fields(commandLine)
+		includeUserCategories := len(arguments) > 3 && arguments[3] == "all"
+		deleted, err := sideBar.DeleteAllSidebarCategories(includeUserCategories)
+		if err != nil && deleted == nil {
+			return renderError(err)
+		}
+		return withError("Deleted: "+strings.Join(deleted, ", "), err)
+
+	case "offboard":
+		if user == nil {
+			return "Missing user name"
+		}
+		arguments := strings.Fields(commandLine)
+		deactivate := len(arguments) > 3 && arguments[3] == "deactivate"
+		result, err := sideBar.Offboard(c.User.Username, deactivate)
+		if err != nil && result == nil {
+			return renderError(err)
+		}
+		return withError(renderOffboarding(user.Username, result), err)
+
+	case "audit":
+		entries, err := business.AuditTrail(c)
+		if err != nil {
+			return renderError(err)
+		}
+		return renderAudit(entries)
+
+	case "respect_choice":
+		if user == nil {
+			return "Missing user name"
+		}
+		arguments := strings.Fields(commandLine)
+		if len(arguments) < 4 || (arguments[3] != "on" && arguments[3] != "off") {
+			return "Usage: /anchor respect_choice <user> on|off"
+		}
+		respect := arguments[3] == "on"
+		if err := user.SetRespectCategorySettings(respect); err != nil {
+			return renderError(err)
+		}
+		if respect {
+			return "Category settings of **" + user.Username + "** will be respected."
+		}
+		return "Category policies will be applied to **" + user.Username + "**."
+
+	case "sidebar":
+		if user == nil {
+			return "Missing user name"
+		}
+		plan, err := sideBar.PlanSidebar()
+		if err != nil {
+			return renderError(err)
+		}
+		arguments := strings.Fields(commandLine)
+		if len(arguments) < 4 || arguments[3] != "apply" {
+			return renderSidebarPlan(user.Username, plan, false)
+		}
+		return withError(renderSidebarPlan(user.Username, plan, true), sideBar.ApplySidebarPlan(plan))
+
+	case "notifications":
+		if user == nil {
+			return "Missing user name"
+		}
+		structure, err := user.Structure()
+		if err != nil {
+			return renderError(err)
+		}
+		arguments := strings.Fields(commandLine)
+		reset := len(arguments) > 3 && arguments[3] == "reset"
+		drifts, err := user.ReconcileNotifyProps(structure, reset)
+		if err != nil && drifts == nil {
+			return renderError(err)
+		}
+		return withError(renderNotifyDrifts(user.Username, drifts, reset), err)
 
 	case "reorder":
 
@@ -160,7 +393,11 @@ func (p *AnchorPlugin) GetCommandResponse(_ *plugin.Context, commandLine string)
 			return "Missing user name"
 		}
 
-		return sideBar.ReorderSidebarCategories()
+		result, err := sideBar.ReorderSidebarCategories()
+		if err != nil {
+			return renderError(err)
+		}
+		return renderReorder(result)
 
 	case "debug":
 
@@ -168,20 +405,20 @@ func (p *AnchorPlugin) GetCommandResponse(_ *plugin.Context, commandLine string)
 			return "Missing user name"
 		}
 
-		actualCategories, err := sideBar.SidebarCategoryNames()
+		channels, err := team.PublicChannels()
 		if err != nil {
-			return err.Error()
+			return renderError(err)
 		}
 
 		return strings.Join([]string{
 			"**Default Channels:**",
 			strings.Join(config.ChannelNames(), "\n"),
 			"\n**Subscribed Channels**",
-			team.GetChannelsListString(),
+			renderChannels(channels),
 			"\n**Default Categories:**",
 			strings.Join(config.CategoryNames(), "\n"),
 			"\n**Actual Categories:**",
-			strings.Join(actualCategories, "\n"),
+			strings.Join(sideBar.SidebarCategoryNames(), "\n"),
 		}, "\n")
 
 	default:
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"github.com/glass.plugin-anchor/server/business"
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/fakeapi"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Regenerate the fixtures with: go test ./server -run TestCommandGolden -update
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// newGoldenPlugin seeds a team holding the structure, with "skipper" onboarded
+// and "bosun" only in Town Square.
+func newGoldenPlugin(t *testing.T) *AnchorPlugin {
+	t.Helper()
+
+	p, api := newTestPlugin(model.SystemAdminRoleId + " " + model.SystemUserRoleId)
+	team := p.Context.Team
+
+	for _, category := range config.Structure {
+		for _, entry := range category.Channels {
+			switch {
+			case entry.Name == "town-square":
+			case entry.Private:
+				api.AddChannel(team.Id, entry.Name, entry.DisplayName, model.ChannelTypePrivate)
+			default:
+				api.AddChannel(team.Id, entry.Name, entry.DisplayName, model.ChannelTypeOpen)
+			}
+		}
+	}
+
+	townSquare := p.Context.Channel
+	for _, username := range []string{"skipper", "bosun"} {
+		user := addTeamUser(api, team, username)
+		api.AddMember(townSquare.Id, user.Id)
+	}
+
+	skipper, err := business.NewUser(p.Context, "skipper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sideBar, err := business.NewSideBar(skipper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sideBar.CheckAndJoinDefaultChannelStructure(); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func addTeamUser(api *fakeapi.API, team *model.Team, username string) *model.User {
+	user, appErr := api.GetUserByUsername(username)
+	if appErr != nil {
+		user = api.AddUser(username, model.SystemUserRoleId)
+		api.AddTeamMember(team.Id, user.Id)
+	}
+	return user
+}
+
+func TestCommandGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{"teams", "/anchor teams"},
+		{"users", "/anchor users"},
+		{"channels", "/anchor channels"},
+		{"check_onboarded_user", "/anchor check skipper"},
+		{"check_new_user", "/anchor check bosun"},
+		{"check_team", "/anchor check"},
+		{"stats", "/anchor stats"},
+		{"activity", "/anchor activity --days 7"},
+		{"debug", "/anchor debug bosun"},
+		{"onboard", "/anchor onboard bosun"},
+		{"onboard_all_teams", "/anchor onboard bosun --all-teams"},
+		{"check_all_teams", "/anchor check skipper --all-teams"},
+		{"validate", "/anchor validate"},
+		{"offboard", "/anchor offboard skipper"},
+		{"sidebar_new_user", "/anchor sidebar bosun"},
+		{"notifications_onboarded_user", "/anchor notifications skipper"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newGoldenPlugin(t)
+			got := p.GetCommandResponse(nil, test.command)
+
+			path := filepath.Join("testdata", test.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("output of %q differs from %s\n--- got ---\n%s\n--- want ---\n%s", test.command, path, got, want)
+			}
+		})
+	}
+}
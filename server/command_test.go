@@ -0,0 +1,137 @@
+package main
+
+import (
+	"github.com/glass.plugin-anchor/server/fakeapi"
+	"github.com/glass.plugin-anchor/server/models"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestPlugin(roles string) (*AnchorPlugin, *fakeapi.API) {
+	api := fakeapi.New()
+	team := api.AddTeam("esc")
+	caller := api.AddUser("admin", roles)
+	skipper := api.AddUser("skipper", model.SystemUserRoleId)
+	townSquare := api.AddChannel(team.Id, "town-square", "Town Square", model.ChannelTypeOpen)
+	api.AddTeamMember(team.Id, caller.Id)
+	api.AddTeamMember(team.Id, skipper.Id)
+
+	p := &AnchorPlugin{}
+	p.SetAPI(api)
+	p.botID, _ = p.ensureBot()
+	p.Context = &models.Context{Team: team, Channel: townSquare, User: caller, API: api, Rest: api, BotID: p.botID}
+
+	return p, api
+}
+
+func TestGetCommandResponse(t *testing.T) {
+	admin := model.SystemAdminRoleId + " " + model.SystemUserRoleId
+
+	tests := []struct {
+		name     string
+		roles    string
+		command  string
+		contains string
+	}{
+		{"requires system admin", model.SystemUserRoleId, "/anchor teams", "You do not have permission"},
+		{"requires a command", admin, "/anchor", "missing a command"},
+		{"rejects unknown command", admin, "/anchor sail", "Unknown command"},
+		{"rejects unknown user", admin, "/anchor check nobody", "Not found"},
+		{"lists teams", admin, "/anchor teams", "esc"},
+		{"lists users", admin, "/anchor users", "skipper"},
+		{"lists channels", admin, "/anchor channels", "Town Square"},
+		{"onboard needs a user", admin, "/anchor onboard", "Missing user name"},
+		{"offboard needs a user", admin, "/anchor offboard", "Missing user name"},
+		{"empty audit trail", admin, "/anchor audit", "The audit trail is empty."},
+		{"sidebar needs a user", admin, "/anchor sidebar", "Missing user name"},
+		{"notifications needs a user", admin, "/anchor notifications", "Missing user name"},
+		{"reorder needs a user", admin, "/anchor reorder", "Missing user name"},
+		{"respect_choice needs on or off", admin, "/anchor respect_choice skipper maybe", "Usage"},
+		{"respect_choice", admin, "/anchor respect_choice skipper on", "will be respected"},
+		{"activity needs a number of days", admin, "/anchor activity --days many", "Usage"},
+		{"roster needs import", admin, "/anchor roster", "Usage"},
+		{"roster needs a file", admin, "/anchor roster import", "no CSV file posted"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, _ := newTestPlugin(test.roles)
+
+			response := p.GetCommandResponse(nil, test.command)
+			if !strings.Contains(response, test.contains) {
+				t.Errorf("response = %q, want it to contain %q", response, test.contains)
+			}
+		})
+	}
+}
+
+func TestExecuteCommandAnswersAsBot(t *testing.T) {
+	p, api := newTestPlugin(model.SystemAdminRoleId + " " + model.SystemUserRoleId)
+	args := &model.CommandArgs{Command: "/anchor teams", TeamId: p.Context.Team.Id, ChannelId: p.Context.Channel.Id, UserId: p.Context.User.Id}
+
+	response, appErr := p.ExecuteCommand(nil, args)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if response.Text != "" {
+		t.Errorf("response %q answered by the command, want the bot to answer", response.Text)
+	}
+
+	posts := api.Ephemeral(args.UserId)
+	if len(posts) != 1 || posts[0].UserId != p.botID || posts[0].ChannelId != args.ChannelId || posts[0].Message != "esc" {
+		t.Errorf("ephemeral posts = %v, want the teams from the bot", posts)
+	}
+}
+
+func TestCleanupTeamReportsAsBot(t *testing.T) {
+	p, api := newTestPlugin(model.SystemAdminRoleId + " " + model.SystemUserRoleId)
+	channel := api.AddChannel(p.Context.Team.Id, "club-news", "Club News", model.ChannelTypeOpen)
+	api.AddPost(channel.Id, p.Context.User.Id, model.PostTypeAddToChannel, "skipper added to the channel by admin.")
+
+	response := p.GetCommandResponse(nil, "/anchor cleanup_team apply")
+	if !strings.Contains(response, "Started") {
+		t.Fatalf("response = %q, want the job started", response)
+	}
+	p.jobsDone.Wait()
+
+	direct, appErr := api.GetDirectChannel(p.botID, p.Context.User.Id)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	posts := api.Posts(direct.Id)
+	if len(posts) != 1 || !strings.Contains(posts[0].Message, "- Club News: 1 of 1") {
+		t.Errorf("reports = %v, want the counts of Club News", posts)
+	}
+	if len(api.Posts(channel.Id)) != 0 {
+		t.Errorf("the membership message was not deleted")
+	}
+}
+
+func TestRenderErrorKeepsFailedPermission(t *testing.T) {
+	p, api := newTestPlugin(model.SystemAdminRoleId + " " + model.SystemUserRoleId)
+	api.Fail("REST PUT", http.StatusForbidden)
+
+	response := p.GetCommandResponse(nil, "/anchor reorder skipper")
+	if !strings.Contains(response, "order sidebar categories of user skipper") || strings.Contains(response, "You do not have permission") {
+		t.Errorf("response = %q, want the failed operation", response)
+	}
+}
+
+func TestRosterKeepsMembersNotListedUnlessAsked(t *testing.T) {
+	p, api := newTestPlugin(model.SystemAdminRoleId + " " + model.SystemUserRoleId)
+	laser := api.AddChannel(p.Context.Team.Id, "laser", "Laser", model.ChannelTypeOpen)
+	api.AddMember(laser.Id, p.Context.User.Id)
+	api.AddFilePost(p.Context.Channel.Id, p.Context.User.Id, "crew.csv", []byte("skipper,laser\n"))
+
+	response := p.GetCommandResponse(nil, "/anchor roster import apply")
+	if !strings.Contains(response, "**Not in the roster, kept in Laser:** admin") || !api.IsMember(laser.Id, p.Context.User.Id) {
+		t.Errorf("response = %q, want admin kept in Laser", response)
+	}
+
+	response = p.GetCommandResponse(nil, "/anchor roster import apply remove")
+	if !strings.Contains(response, "**Remove from Laser:** admin") || api.IsMember(laser.Id, p.Context.User.Id) {
+		t.Errorf("response = %q, want admin removed from Laser", response)
+	}
+}
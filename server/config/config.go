@@ -1,29 +1,294 @@
 package config
 
-var CategoryOrder = []string{"Club Life", "Racing", "Cruising", "Fleet", "Training"}
+import (
+	"github.com/mattermost/mattermost-server/v6/model"
+	"time"
+)
 
-var PublicChannels = map[string][]string{
-	"Club Life": {"Town Square", "Club News", "Club House", "Crew Finder", "Market Place", "Car Pool", "Off-Topic"},
-	"Racing":    {"Monday Races", "Seven Bars", "Kaag Cup", "ESA Cup", "Arianes Cup", "Other Races"},
-	"Cruising":  {"Cruising"},
-	"Fleet":     {"Wayfarer", "Randmeer", "Venture", "Laser", "Buzz", "Fox", "Safety Boat", "Booking"},
-	"Training":  {"Sign Up"},
+// Channel binds an entry of the structure to a Mattermost channel. Name is the
+// URL name of the channel (e.g. "club-news"); ID may be set instead to pin the
+// entry to one specific channel. DisplayName is used for messages and as a last
+// resort when neither Name nor ID is given.
+//
+// Public channels are meant for every member of their audience. Private
+// channels are only joined by the plugin when they have an audience. A channel
+// is only joined while Active, see Window. Description introduces the channel
+// in the welcome message. Notify presets the notification preferences of the
+// members the plugin adds.
+type Channel struct {
+	DisplayName string
+	Name        string
+	ID          string
+	Private     bool
+	Description string
+	Audience    Audience
+	Active      Window
+	Notify      NotifyProps
 }
 
-var PrivateChannels = map[string][]string{
-	"Club Life": {"Committee"},
-	"Racing":    {},
-	"Cruising":  {},
-	"Fleet":     {"Fox maintenance and management"},
-	"Training":  {"Instructors", "Training 2024 B", "Training 2024 A", "Training 2023 B"},
+func (c Channel) String() string {
+	switch {
+	case c.DisplayName != "":
+		return c.DisplayName
+	case c.Name != "":
+		return c.Name
+	default:
+		return c.ID
+	}
 }
 
-var DefaultCategories = []string{"Favorites", "Channels", "Direct Messages"} // cannot delete them
+// NotifyProps are the notification preferences of a channel member. An empty
+// field keeps the preference of the member. For example
+//
+//	NotifyProps{Desktop: model.ChannelNotifyMention, MarkUnread: model.ChannelMarkUnreadMention}
+type NotifyProps struct {
+	Desktop    string
+	Push       string
+	MarkUnread string
+}
 
-var (
-	ServerURL = "http://localhost:8065"
-	Headers   = map[string]string{
-		"Authorization": "Bearer " + AuthConfig.AuthToken,
-		"Content-Type":  "application/json",
+func (n NotifyProps) IsZero() bool {
+	return n == NotifyProps{}
+}
+
+// Props returns the preferences set as channel member notify props.
+func (n NotifyProps) Props() map[string]string {
+	props := make(map[string]string)
+	if n.Desktop != "" {
+		props[model.DesktopNotifyProp] = n.Desktop
 	}
+	if n.Push != "" {
+		props[model.PushNotifyProp] = n.Push
+	}
+	if n.MarkUnread != "" {
+		props[model.MarkUnreadNotifyProp] = n.MarkUnread
+	}
+	return props
+}
+
+// CategoryPolicy holds the settings a managed sidebar category gets when it is
+// created for a user during onboarding. Sorting is one of manual, alphabetical
+// and recency sorting.
+type CategoryPolicy struct {
+	Sorting   model.SidebarCategorySorting
+	Collapsed bool
+	Muted     bool
+}
+
+// Category is a managed sidebar category with its channels in sidebar order.
+type Category struct {
+	Name        string
+	Description string
+	Policy      CategoryPolicy
+	Audience    Audience
+	Active      Window
+	Channels    []Channel
+}
+
+// Audience restricts a channel or a whole category to some members. A member
+// belongs to it if they are in one of the Groups, have one of the TeamRoles
+// (e.g. "team_admin") or have one of the profile Props set to the given value.
+// The empty audience is every member. For example
+//
+//	Audience{Groups: []string{"instructors"}}
+//	Audience{Props: map[string]string{"crew": "racing"}}
+type Audience struct {
+	Groups    []string
+	TeamRoles []string
+	Props     map[string]string
+}
+
+// Window is the period a channel or category is active in, such as a racing
+// season or a training cohort. A zero From or Until leaves the window open on
+// that side. For example
+//
+//	Window{From: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), Until: time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)}
+//
+// When the window ended, the channel moves to the ArchiveCategory of its
+// members, or is archived if ArchiveEndedChannels is set.
+type Window struct {
+	From  time.Time
+	Until time.Time
+}
+
+func (w Window) Started(now time.Time) bool {
+	return w.From.IsZero() || !now.Before(w.From)
+}
+
+func (w Window) Ended(now time.Time) bool {
+	return !w.Until.IsZero() && !now.Before(w.Until)
+}
+
+func (w Window) Contains(now time.Time) bool {
+	return w.Started(now) && !w.Ended(now)
+}
+
+func (a Audience) Everyone() bool {
+	return len(a.Groups) == 0 && len(a.TeamRoles) == 0 && len(a.Props) == 0
+}
+
+// quiet is for busy channels members only need to hear of when mentioned.
+var quiet = NotifyProps{Desktop: model.ChannelNotifyMention, Push: model.ChannelNotifyMention, MarkUnread: model.ChannelMarkUnreadMention}
+
+// Structure is the channel structure of the club. Categories and channels are
+// created, checked and listed in this order.
+var Structure = []Category{
+	{
+		Name:        "Club Life",
+		Description: "Everything happening in and around the club.",
+		Policy:      CategoryPolicy{Sorting: model.SidebarCategorySortManual},
+		Channels: []Channel{
+			{DisplayName: "Town Square", Name: "town-square"},
+			{DisplayName: "Club News", Name: "club-news", Description: "Announcements of the committee."},
+			{DisplayName: "Club House", Name: "club-house"},
+			{DisplayName: "Crew Finder", Name: "crew-finder", Description: "Look for a crew or a boat to sail on."},
+			{DisplayName: "Market Place", Name: "market-place", Notify: quiet},
+			{DisplayName: "Car Pool", Name: "car-pool", Notify: quiet},
+			{DisplayName: "Off-Topic", Name: "off-topic", Notify: quiet},
+			{DisplayName: "Committee", Name: "committee", Private: true},
+		},
+	},
+	{
+		Name:        "Racing",
+		Description: "Race announcements, results and crews.",
+		Policy:      CategoryPolicy{Sorting: model.SidebarCategorySortManual},
+		Channels: []Channel{
+			{DisplayName: "Monday Races", Name: "monday-races"},
+			{DisplayName: "Seven Bars", Name: "seven-bars"},
+			{DisplayName: "Kaag Cup", Name: "kaag-cup"},
+			{DisplayName: "ESA Cup", Name: "esa-cup"},
+			{DisplayName: "Arianes Cup", Name: "arianes-cup"},
+			{DisplayName: "Other Races", Name: "other-races"},
+		},
+	},
+	{
+		Name:        "Cruising",
+		Description: "Trips and cruises together.",
+		Policy:      CategoryPolicy{Sorting: model.SidebarCategorySortRecent},
+		Channels: []Channel{
+			{DisplayName: "Cruising", Name: "cruising"},
+		},
+	},
+	{
+		Name:        "Fleet",
+		Description: "One channel per boat type, and boat bookings.",
+		Policy:      CategoryPolicy{Sorting: model.SidebarCategorySortAlphabetical, Collapsed: true},
+		Channels: []Channel{
+			{DisplayName: "Wayfarer", Name: "wayfarer"},
+			{DisplayName: "Randmeer", Name: "randmeer"},
+			{DisplayName: "Venture", Name: "venture"},
+			{DisplayName: "Laser", Name: "laser"},
+			{DisplayName: "Buzz", Name: "buzz"},
+			{DisplayName: "Fox", Name: "fox"},
+			{DisplayName: "Safety Boat", Name: "safety-boat"},
+			{DisplayName: "Booking", Name: "booking", Description: "Book the club boats."},
+			{DisplayName: "Fox maintenance and management", Name: "fox-maintenance-and-management", Private: true},
+		},
+	},
+	{
+		Name:        "Training",
+		Description: "Courses and sign-up for training.",
+		Policy:      CategoryPolicy{Sorting: model.SidebarCategorySortManual, Collapsed: true},
+		Channels: []Channel{
+			{DisplayName: "Sign Up", Name: "sign-up", Description: "Sign up for the next course."},
+			{DisplayName: "Instructors", Name: "instructors", Private: true},
+			{DisplayName: "Training 2024 B", Name: "training-2024-b", Private: true},
+			{DisplayName: "Training 2024 A", Name: "training-2024-a", Private: true},
+			{DisplayName: "Training 2023 B", Name: "training-2023-b", Private: true},
+		},
+	},
+}
+
+// The bot account the plugin sends its messages from.
+const (
+	BotUsername    = "anchor"
+	BotDisplayName = "Anchor"
+	BotDescription = "Sets up the channels and sidebar of club members."
 )
+
+// KeyChannels are linked in the welcome message by their URL name.
+var KeyChannels = []string{"club-news", "crew-finder"}
+
+// SendWelcome makes onboarding send the member a direct message from the bot
+// introducing the channels they were added to, rendered from WelcomeTemplate.
+var SendWelcome = true
+
+const WelcomeTemplate = `Welcome to **{{.Team}}**, {{.Name}}!
+
+You were added to these channels:
+{{range .Categories}}
+**{{.Name}}**{{if .Description}} - {{.Description}}{{end}}
+{{range .Channels}}- ~{{.Name}}{{if .Description}}: {{.Description}}{{end}}
+{{end}}{{end}}{{if .KeyChannels}}
+Start with {{range $i, $channel := .KeyChannels}}{{if $i}}, {{end}}~{{$channel}}{{end}}.{{end}}
+`
+
+var CategoryOrder = []string{"Club Life", "Racing", "Cruising", "Fleet", "Training"}
+
+// EnforceCategoryPolicies makes reordering reset the sorting, collapsed and
+// muted state of managed categories, except for users who asked to keep their
+// own choice.
+var EnforceCategoryPolicies = false
+
+// Slots of SidebarOrder for the managed categories, in CategoryOrder, and for
+// the categories users created themselves.
+const (
+	ManagedCategories = "*managed*"
+	UserCategories    = "*user*"
+)
+
+// SidebarOrder is the order of the sidebar categories set by reordering: the
+// built-in categories by name and the slots of the other categories.
+// Categories not placed follow in their previous order.
+var SidebarOrder = []string{"Favorites", ManagedCategories, UserCategories, "Channels", "Direct Messages"}
+
+// OnboardingAttempts is how often an onboarding step is tried when the server
+// fails with a transient error. The wait between attempts starts at
+// OnboardingBackoff and doubles with every retry.
+var (
+	OnboardingAttempts = 3
+	OnboardingBackoff  = 500 * time.Millisecond
+)
+
+// OffboardOnLeave offboards members when they leave a team, as
+// /anchor offboard does. DeactivateOnLeave also deactivates their account.
+var (
+	OffboardOnLeave   = false
+	DeactivateOnLeave = false
+)
+
+// SuppressMembershipPosts keeps the "added to the channel" messages caused by
+// onboarding and by the join hook from being posted. The messages posted
+// anyway are queued for /anchor cleanup_queued.
+var SuppressMembershipPosts = true
+
+// RosterRoles can be listed in a roster instead of channels, each standing for
+// the channels given here by their URL name.
+var RosterRoles = map[string][]string{
+	"racing-crew": {"monday-races", "seven-bars", "kaag-cup", "esa-cup", "arianes-cup", "other-races"},
+	"instructor":  {"instructors", "safety-boat"},
+}
+
+// StatsTop is how many channels, categories and members /anchor stats lists
+// as most often missing and drifting furthest from the structure.
+const StatsTop = 10
+
+// ActivityDays is the period /anchor activity reports on by default.
+const ActivityDays = 30
+
+// A team-wide cleanup deletes posts in batches of CleanupBatchSize, pausing
+// CleanupBatchPause between batches.
+const (
+	CleanupBatchSize  = 50
+	CleanupBatchPause = time.Second
+)
+
+// ArchiveCategory collects the channels of the structure whose window ended,
+// unless ArchiveEndedChannels archives those channels instead.
+const ArchiveCategory = "Archive"
+
+var ArchivePolicy = CategoryPolicy{Sorting: model.SidebarCategorySortAlphabetical, Collapsed: true}
+
+var ArchiveEndedChannels = false
+
+var DefaultCategories = []string{"Favorites", "Channels", "Direct Messages"} // cannot delete them
@@ -1,41 +1,136 @@
 package config
 
+import (
+	"github.com/mattermost/mattermost-server/v6/model"
+	"time"
+)
+
+// ChannelNames lists the public channels of the structure in order.
 func ChannelNames() []string {
 	var channels []string
 
-	for _, channelList := range PublicChannels {
-		channels = append(channels, channelList...)
+	for _, category := range PublicStructure() {
+		for _, channel := range category.Channels {
+			channels = append(channels, channel.String())
+		}
 	}
 	return channels
 }
 
+// CategoryNames lists the categories of the structure in order.
 func CategoryNames() []string {
 	var categories []string
 
-	for category := range PublicChannels {
-		categories = append(categories, category)
+	for _, category := range Structure {
+		categories = append(categories, category.Name)
 	}
 	return categories
 }
 
-func AllChannels() map[string][]string {
-	merged := make(map[string][]string)
+// PublicStructure returns the structure restricted to its public channels,
+// which members can be added to without an invitation.
+func PublicStructure() []Category {
+	var public []Category
+
+	for _, category := range Structure {
+		publicCategory := Category{Name: category.Name, Policy: category.Policy, Audience: category.Audience}
+		for _, channel := range category.Channels {
+			if !channel.Private {
+				publicCategory.Channels = append(publicCategory.Channels, channel)
+			}
+		}
+		public = append(public, publicCategory)
+	}
+	return public
+}
+
+// StructureFor returns the structure meant for one member at the given time:
+// the active public channels and the active private channels with an
+// audience, as far as the member belongs to the audiences of the channel and
+// of its category. Categories left without channels are dropped. belongs
+// tells whether the member belongs to an audience that is not everyone.
+func StructureFor(now time.Time, belongs func(Audience) (bool, error)) ([]Category, error) {
+	var structure []Category
+
+	for _, category := range Structure {
+		if !category.Active.Contains(now) {
+			continue
+		}
+		if !category.Audience.Everyone() {
+			member, err := belongs(category.Audience)
+			if err != nil {
+				return nil, err
+			}
+			if !member {
+				continue
+			}
+		}
+
+		memberCategory := Category{Name: category.Name, Policy: category.Policy, Audience: category.Audience, Active: category.Active}
+		for _, channel := range category.Channels {
+			if !channel.Active.Contains(now) {
+				continue
+			}
+			if channel.Audience.Everyone() {
+				if !channel.Private {
+					memberCategory.Channels = append(memberCategory.Channels, channel)
+				}
+				continue
+			}
+
+			member, err := belongs(channel.Audience)
+			if err != nil {
+				return nil, err
+			}
+			if member {
+				memberCategory.Channels = append(memberCategory.Channels, channel)
+			}
+		}
+
+		if len(memberCategory.Channels) > 0 {
+			structure = append(structure, memberCategory)
+		}
+	}
+	return structure, nil
+}
+
+// EndedChannels lists the channels of the structure whose window, or the
+// window of their category, ended at the given time.
+func EndedChannels(now time.Time) []Channel {
+	var ended []Channel
 
-	// First, add channels from PublicChannels in order
-	for category, channels := range PublicChannels {
-		merged[category] = append(merged[category], channels...)
-		// Add channels from PrivateChannels if the category exists
-		if privateChannels, exists := PrivateChannels[category]; exists {
-			merged[category] = append(merged[category], privateChannels...)
+	for _, category := range Structure {
+		for _, channel := range category.Channels {
+			if category.Active.Ended(now) || channel.Active.Ended(now) {
+				ended = append(ended, channel)
+			}
 		}
 	}
+	return ended
+}
 
-	// Next, add any categories from PrivateChannels that are not in PublicChannels
-	for category, channels := range PrivateChannels {
-		if _, exists := merged[category]; !exists {
-			merged[category] = append(merged[category], channels...)
+func FindCategory(name string) (Category, bool) {
+	for _, category := range Structure {
+		if category.Name == name {
+			return category, true
 		}
 	}
+	return Category{}, false
+}
+
+// IsManagedCategory tells whether the plugin manages the category, which are
+// the categories of the structure and the ArchiveCategory.
+func IsManagedCategory(category string) bool {
+	_, exists := FindCategory(category)
+	return exists || category == ArchiveCategory
+}
 
-	return merged
+func Policy(category string) CategoryPolicy {
+	if category == ArchiveCategory {
+		return ArchivePolicy
+	}
+	if managed, exists := FindCategory(category); exists {
+		return managed.Policy
+	}
+	return CategoryPolicy{Sorting: model.SidebarCategorySortManual}
 }
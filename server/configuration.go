@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"github.com/glass.plugin-anchor/server/api"
+	"strings"
+)
+
+// configuration holds the settings of the plugin from the System Console, as
+// declared in the settings_schema of plugin.json.
+type configuration struct {
+	// AccessToken is a personal access token of a system admin, used for
+	// the sidebar endpoints of the REST API the plugin API lacks.
+	AccessToken string
+}
+
+// getConfiguration returns the active configuration. It is never nil and must
+// not be changed.
+func (p *AnchorPlugin) getConfiguration() *configuration {
+	p.configurationLock.RLock()
+	defer p.configurationLock.RUnlock()
+
+	if p.configuration == nil {
+		return &configuration{}
+	}
+	return p.configuration
+}
+
+func (p *AnchorPlugin) setConfiguration(configuration *configuration) {
+	p.configurationLock.Lock()
+	defer p.configurationLock.Unlock()
+
+	p.configuration = configuration
+}
+
+// OnConfigurationChange loads the settings whenever an admin changes them.
+func (p *AnchorPlugin) OnConfigurationChange() error {
+	configuration := new(configuration)
+
+	if err := p.API.LoadPluginConfiguration(configuration); err != nil {
+		return fmt.Errorf("failed to load plugin configuration: %w", err)
+	}
+
+	if configuration.AccessToken == "" {
+		p.API.LogWarn("No access token configured, sidebar categories cannot be deleted or ordered")
+	}
+
+	p.setConfiguration(configuration)
+	return nil
+}
+
+// siteURL returns the URL of the server as configured in the System Console.
+func (p *AnchorPlugin) siteURL() string {
+	config := p.API.GetConfig()
+	if config == nil || config.ServiceSettings.SiteURL == nil {
+		return ""
+	}
+	return strings.TrimSuffix(*config.ServiceSettings.SiteURL, "/")
+}
+
+// restClient returns a client of the REST API authenticated with the access
+// token of the settings.
+func (p *AnchorPlugin) restClient() *api.RestClient {
+	token := p.getConfiguration().AccessToken
+	return api.NewRestClient(p.siteURL(), token, map[string]string{
+		"Authorization": "Bearer " + token,
+		"Content-Type":  "application/json",
+	})
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRestClientFromSettings(t *testing.T) {
+	p, api := newTestPlugin("")
+	api.SetSiteURL("https://chat.example.com/")
+	api.SetPluginSetting("AccessToken", "s3cr3t")
+
+	if err := p.OnConfigurationChange(); err != nil {
+		t.Fatal(err)
+	}
+
+	client := p.restClient()
+	if client.ServerURL != "https://chat.example.com" {
+		t.Errorf("server URL = %q, want the site URL", client.ServerURL)
+	}
+	if client.AuthToken != "s3cr3t" || client.Headers["Authorization"] != "Bearer s3cr3t" {
+		t.Errorf("client is not authenticated with the access token: %+v", client)
+	}
+}
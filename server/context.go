@@ -1,8 +1,6 @@
 package main
 
 import (
-	"github.com/glass.plugin-anchor/server/api"
-	"github.com/glass.plugin-anchor/server/config"
 	"github.com/glass.plugin-anchor/server/models"
 	"github.com/mattermost/mattermost-server/v6/model"
 )
@@ -40,11 +38,21 @@ func (p *AnchorPlugin) SetContextFromCommandArgs(args *model.CommandArgs) *model
 	p.Context.User = user
 
 	// Optionally set other fields
-	p.Context.API = p.API
-	p.Context.Auth = config.AuthConfig
-	p.Context.Rest = api.NewRestClient(config.ServerURL, p.Context.Auth.AuthToken, config.Headers)
-
-	p.API.LogWarn("AUTH CONFIG", "ServerURL", config.ServerURL, "AuthToken", p.Context.Auth.AuthToken, "Headers", config.Headers)
+	p.connect(p.Context)
 
 	return nil
 }
+
+// NewHookContext returns a context for a hook acting in the team on behalf of
+// the user, who may be nil.
+func (p *AnchorPlugin) NewHookContext(team *model.Team, user *model.User) *models.Context {
+	c := &models.Context{Team: team, User: user}
+	p.connect(c)
+	return c
+}
+
+func (p *AnchorPlugin) connect(c *models.Context) {
+	c.API = p.API
+	c.BotID = p.botID
+	c.Rest = p.restClient()
+}
@@ -0,0 +1,1093 @@
+// Package fakeapi provides an in-memory stand-in for the subset of plugin.API
+// and of the REST API the plugin uses, so the business logic and the commands
+// can be tested without a live server.
+//
+// Methods outside of that subset are left to the embedded plugin.API, which is
+// nil, and panic when called.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"github.com/mattermost/mattermost-server/v6/plugin"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PostHooks are the plugin hooks the fake runs for the membership messages it
+// posts, as the server runs them for every post.
+type PostHooks interface {
+	MessageWillBePosted(c *plugin.Context, post *model.Post) (*model.Post, string)
+	MessageHasBeenPosted(c *plugin.Context, post *model.Post)
+}
+
+type API struct {
+	plugin.API
+
+	mu sync.Mutex
+
+	teams       []*model.Team
+	users       []*model.User
+	channels    []*model.Channel
+	teamMembers map[string][]string                             // team ID -> user IDs
+	teamRoles   map[string]string                               // team ID + user ID -> roles
+	groups      map[string][]*model.Group                       // user ID -> groups
+	members     map[string][]*model.ChannelMember               // channel ID -> members
+	posts       map[string][]*model.Post                        // channel ID -> posts, oldest first
+	sidebars    map[string][]*model.SidebarCategoryWithChannels // user ID + team ID -> categories in order
+	ephemeral   map[string][]*model.Post                        // user ID -> ephemeral posts
+	files       map[string]*model.FileInfo                      // file ID -> info
+	fileData    map[string][]byte                               // file ID -> content
+	kv          map[string][]byte
+
+	config       *model.Config
+	pluginConfig map[string]interface{}
+	postHooks    PostHooks
+
+	calls    map[string]int
+	failures map[string][]int // method -> status codes of the next calls to fail
+	Logs     []string
+}
+
+func New() *API {
+	return &API{
+		teamMembers:  make(map[string][]string),
+		teamRoles:    make(map[string]string),
+		groups:       make(map[string][]*model.Group),
+		members:      make(map[string][]*model.ChannelMember),
+		posts:        make(map[string][]*model.Post),
+		sidebars:     make(map[string][]*model.SidebarCategoryWithChannels),
+		ephemeral:    make(map[string][]*model.Post),
+		files:        make(map[string]*model.FileInfo),
+		fileData:     make(map[string][]byte),
+		kv:           make(map[string][]byte),
+		calls:        make(map[string]int),
+		config:       &model.Config{},
+		pluginConfig: make(map[string]interface{}),
+		failures:     make(map[string][]int),
+	}
+}
+
+// Seeding
+
+func (a *API) AddTeam(name string) *model.Team {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	team := &model.Team{Id: model.NewId(), Name: name, DisplayName: name, Type: model.TeamOpen}
+	a.teams = append(a.teams, team)
+	return team
+}
+
+func (a *API) AddUser(username string, roles string) *model.User {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user := &model.User{
+		Id:        model.NewId(),
+		Username:  username,
+		FirstName: strings.ToUpper(username[:1]) + username[1:],
+		Email:     username + "@example.com",
+		Roles:     roles,
+	}
+	a.users = append(a.users, user)
+	return user
+}
+
+func (a *API) AddTeamMember(teamID, userID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.teamMembers[teamID] = append(a.teamMembers[teamID], userID)
+}
+
+// SetTeamRoles sets the explicit roles of a team member, e.g. "team_admin".
+func (a *API) SetTeamRoles(teamID, userID, roles string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.teamRoles[teamID+"/"+userID] = roles
+}
+
+// AddGroupMember adds the user to the group with the given name.
+func (a *API) AddGroupMember(groupName, userID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name := groupName
+	a.groups[userID] = append(a.groups[userID], &model.Group{Id: model.NewId(), Name: &name, DisplayName: groupName})
+}
+
+func (a *API) AddChannel(teamID, name, displayName string, channelType model.ChannelType) *model.Channel {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	channel := &model.Channel{Id: model.NewId(), TeamId: teamID, Name: name, DisplayName: displayName, Type: channelType}
+	a.channels = append(a.channels, channel)
+	return channel
+}
+
+// AddMember makes the user a member of the channel without posting anything.
+func (a *API) AddMember(channelID, userID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.addMember(channelID, userID)
+}
+
+func (a *API) AddPost(channelID, userID, postType, message string) *model.Post {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.addPost(channelID, userID, postType, message)
+}
+
+// AddFilePost posts a message with a file attachment.
+func (a *API) AddFilePost(channelID, userID, fileName string, data []byte) *model.Post {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info := &model.FileInfo{
+		Id:        model.NewId(),
+		CreatorId: userID,
+		ChannelId: channelID,
+		Name:      fileName,
+		Extension: strings.TrimPrefix(filepath.Ext(fileName), "."),
+		Size:      int64(len(data)),
+	}
+	a.files[info.Id] = info
+	a.fileData[info.Id] = data
+
+	post := a.newPost(channelID, userID, "", "")
+	post.FileIds = model.StringArray{info.Id}
+	a.storePost(post)
+	return post
+}
+
+// Fail makes the next calls to the method fail with the given status codes,
+// one per call; http.StatusOK lets a call through. Only the methods that
+// change memberships or the sidebar honour it.
+func (a *API) Fail(method string, statusCodes ...int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.failures[method] = append(a.failures[method], statusCodes...)
+}
+
+// SetSiteURL sets the site URL of the server configuration.
+func (a *API) SetSiteURL(siteURL string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.config.ServiceSettings.SiteURL = model.NewString(siteURL)
+}
+
+// SetPluginSetting sets a setting of the plugin, as an admin does in the
+// System Console.
+func (a *API) SetPluginSetting(key string, value interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pluginConfig[key] = value
+}
+
+// SetPostHooks makes the fake run the hooks for membership messages.
+func (a *API) SetPostHooks(hooks PostHooks) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.postHooks = hooks
+}
+
+// Inspection
+
+// Calls returns the number of calls made to the given method, or to all
+// methods if the name is empty.
+func (a *API) Calls(method string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if method != "" {
+		return a.calls[method]
+	}
+
+	total := 0
+	for _, count := range a.calls {
+		total += count
+	}
+	return total
+}
+
+func (a *API) ResetCalls() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.calls = make(map[string]int)
+}
+
+func (a *API) IsMember(channelID, userID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.member(channelID, userID) != nil
+}
+
+func (a *API) Posts(channelID string) []*model.Post {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]*model.Post(nil), a.posts[channelID]...)
+}
+
+// Ephemeral returns the ephemeral posts sent to the user, oldest first.
+func (a *API) Ephemeral(userID string) []*model.Post {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]*model.Post(nil), a.ephemeral[userID]...)
+}
+
+// Categories returns a copy of the user's sidebar categories in their order.
+func (a *API) Categories(userID, teamID string) []*model.SidebarCategoryWithChannels {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return copyCategories(a.sidebar(userID, teamID))
+}
+
+// Teams
+
+func (a *API) GetTeam(teamID string) (*model.Team, *model.AppError) {
+	defer a.enter("GetTeam")()
+	for _, team := range a.teams {
+		if team.Id == teamID {
+			return team, nil
+		}
+	}
+	return nil, notFound("GetTeam", teamID)
+}
+
+func (a *API) GetTeams() ([]*model.Team, *model.AppError) {
+	defer a.enter("GetTeams")()
+	return append([]*model.Team(nil), a.teams...), nil
+}
+
+func (a *API) GetTeamByName(name string) (*model.Team, *model.AppError) {
+	defer a.enter("GetTeamByName")()
+	return a.teamByName(name)
+}
+
+func (a *API) GetUsersInTeam(teamID string, page, perPage int) ([]*model.User, *model.AppError) {
+	defer a.enter("GetUsersInTeam")()
+
+	var users []*model.User
+	for _, userID := range a.teamMembers[teamID] {
+		users = append(users, a.user(userID))
+	}
+	return pageOf(users, page, perPage), nil
+}
+
+func (a *API) GetTeamsForUser(userID string) ([]*model.Team, *model.AppError) {
+	defer a.enter("GetTeamsForUser")()
+
+	var teams []*model.Team
+	for _, team := range a.teams {
+		for _, memberID := range a.teamMembers[team.Id] {
+			if memberID == userID {
+				teams = append(teams, team)
+				break
+			}
+		}
+	}
+	return teams, nil
+}
+
+func (a *API) GetTeamMember(teamID, userID string) (*model.TeamMember, *model.AppError) {
+	defer a.enter("GetTeamMember")()
+
+	for _, memberID := range a.teamMembers[teamID] {
+		if memberID == userID {
+			return &model.TeamMember{TeamId: teamID, UserId: userID, Roles: a.teamRoles[teamID+"/"+userID], SchemeUser: true}, nil
+		}
+	}
+	return nil, notFound("GetTeamMember", userID)
+}
+
+// Groups
+
+func (a *API) GetGroupsForUser(userID string) ([]*model.Group, *model.AppError) {
+	defer a.enter("GetGroupsForUser")()
+	return append([]*model.Group(nil), a.groups[userID]...), nil
+}
+
+// Users
+
+// CreateBot creates the bot with its user, as the server does.
+func (a *API) CreateBot(bot *model.Bot) (*model.Bot, *model.AppError) {
+	defer a.enter("CreateBot")()
+
+	for _, user := range a.users {
+		if user.Username == bot.Username {
+			return nil, model.NewAppError("CreateBot", "app.user.save.username_exists.app_error", nil, "", http.StatusBadRequest)
+		}
+	}
+
+	user := &model.User{Id: model.NewId(), Username: bot.Username, FirstName: bot.DisplayName, IsBot: true, Roles: model.SystemUserRoleId}
+	a.users = append(a.users, user)
+
+	created := *bot
+	created.UserId = user.Id
+	return &created, nil
+}
+
+func (a *API) UpdateUserActive(userID string, active bool) *model.AppError {
+	defer a.enter("UpdateUserActive")()
+
+	user := a.user(userID)
+	if user == nil {
+		return notFound("UpdateUserActive", userID)
+	}
+	if active {
+		user.DeleteAt = 0
+	} else {
+		user.DeleteAt = model.GetMillis()
+	}
+	return nil
+}
+
+func (a *API) GetUser(userID string) (*model.User, *model.AppError) {
+	defer a.enter("GetUser")()
+	if user := a.user(userID); user != nil {
+		return user, nil
+	}
+	return nil, notFound("GetUser", userID)
+}
+
+func (a *API) GetUserByUsername(username string) (*model.User, *model.AppError) {
+	defer a.enter("GetUserByUsername")()
+	for _, user := range a.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, notFound("GetUserByUsername", username)
+}
+
+func (a *API) GetUserByEmail(email string) (*model.User, *model.AppError) {
+	defer a.enter("GetUserByEmail")()
+	for _, user := range a.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, notFound("GetUserByEmail", email)
+}
+
+func (a *API) GetUsers(options *model.UserGetOptions) ([]*model.User, *model.AppError) {
+	defer a.enter("GetUsers")()
+	return pageOf(a.users, options.Page, options.PerPage), nil
+}
+
+// Channels
+
+func (a *API) GetChannel(channelID string) (*model.Channel, *model.AppError) {
+	defer a.enter("GetChannel")()
+	if channel := a.channel(channelID); channel != nil {
+		return channel, nil
+	}
+	return nil, notFound("GetChannel", channelID)
+}
+
+func (a *API) GetChannelByName(teamID, name string, includeDeleted bool) (*model.Channel, *model.AppError) {
+	defer a.enter("GetChannelByName")()
+	for _, channel := range a.channels {
+		if channel.TeamId == teamID && channel.Name == name && (includeDeleted || channel.DeleteAt == 0) {
+			return channel, nil
+		}
+	}
+	return nil, notFound("GetChannelByName", name)
+}
+
+func (a *API) GetChannelByNameForTeamName(teamName, channelName string, includeDeleted bool) (*model.Channel, *model.AppError) {
+	defer a.enter("GetChannelByNameForTeamName")()
+	team, appErr := a.teamByName(teamName)
+	if appErr != nil {
+		return nil, appErr
+	}
+	for _, channel := range a.channels {
+		if channel.TeamId == team.Id && channel.Name == channelName && (includeDeleted || channel.DeleteAt == 0) {
+			return channel, nil
+		}
+	}
+	return nil, notFound("GetChannelByNameForTeamName", channelName)
+}
+
+func (a *API) GetPublicChannelsForTeam(teamID string, page, perPage int) ([]*model.Channel, *model.AppError) {
+	defer a.enter("GetPublicChannelsForTeam")()
+
+	var channels []*model.Channel
+	for _, channel := range a.channels {
+		if channel.TeamId == teamID && channel.Type == model.ChannelTypeOpen && channel.DeleteAt == 0 {
+			channels = append(channels, channel)
+		}
+	}
+	return pageOf(channels, page, perPage), nil
+}
+
+func (a *API) GetChannelsForTeamForUser(teamID, userID string, includeDeleted bool) ([]*model.Channel, *model.AppError) {
+	defer a.enter("GetChannelsForTeamForUser")()
+
+	var channels []*model.Channel
+	for _, channel := range a.channels {
+		if channel.TeamId == teamID && (includeDeleted || channel.DeleteAt == 0) && a.member(channel.Id, userID) != nil {
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
+
+func (a *API) CreateChannel(channel *model.Channel) (*model.Channel, *model.AppError) {
+	defer a.enter("CreateChannel")()
+
+	for _, existing := range a.channels {
+		if existing.TeamId == channel.TeamId && existing.Name == channel.Name {
+			return nil, model.NewAppError("CreateChannel", "store.sql_channel.save_channel.exists.app_error", nil, "", http.StatusBadRequest)
+		}
+	}
+
+	created := *channel
+	created.Id = model.NewId()
+	a.channels = append(a.channels, &created)
+	return &created, nil
+}
+
+// DeleteChannel archives the channel, as the server does.
+func (a *API) DeleteChannel(channelID string) *model.AppError {
+	defer a.enter("DeleteChannel")()
+
+	channel := a.channel(channelID)
+	if channel == nil || channel.DeleteAt != 0 {
+		return notFound("DeleteChannel", channelID)
+	}
+	channel.DeleteAt = model.GetMillis()
+	return nil
+}
+
+func (a *API) GetChannelMember(channelID, userID string) (*model.ChannelMember, *model.AppError) {
+	defer a.enter("GetChannelMember")()
+	if member := a.member(channelID, userID); member != nil {
+		return member, nil
+	}
+	return nil, notFound("GetChannelMember", channelID)
+}
+
+func (a *API) GetChannelMembers(channelID string, page, perPage int) (model.ChannelMembers, *model.AppError) {
+	defer a.enter("GetChannelMembers")()
+
+	var members model.ChannelMembers
+	for _, member := range pageOf(a.members[channelID], page, perPage) {
+		members = append(members, *member)
+	}
+	return members, nil
+}
+
+func (a *API) UpdateChannelMemberNotifications(channelID, userID string, notifications map[string]string) (*model.ChannelMember, *model.AppError) {
+	defer a.enter("UpdateChannelMemberNotifications")()
+	if appErr := a.injected("UpdateChannelMemberNotifications"); appErr != nil {
+		return nil, appErr
+	}
+
+	member := a.member(channelID, userID)
+	if member == nil {
+		return nil, notFound("UpdateChannelMemberNotifications", channelID)
+	}
+	for key, value := range notifications {
+		member.NotifyProps[key] = value
+	}
+	return member, nil
+}
+
+// AddChannelMember adds the user and posts a join message, as the server does.
+func (a *API) AddChannelMember(channelID, userID string) (*model.ChannelMember, *model.AppError) {
+	return a.joinChannel("AddChannelMember", channelID, userID, "")
+}
+
+// AddUserToChannel adds the user on behalf of another one and posts an "added
+// to the channel by" message, as the server does.
+func (a *API) AddUserToChannel(channelID, userID, asUserID string) (*model.ChannelMember, *model.AppError) {
+	return a.joinChannel("AddUserToChannel", channelID, userID, asUserID)
+}
+
+// DeleteChannelMember removes the user from the channel and from the sidebar
+// categories, posting a leave message. The default channel cannot be left.
+func (a *API) DeleteChannelMember(channelID, userID string) *model.AppError {
+	defer a.enter("DeleteChannelMember")()
+	if appErr := a.injected("DeleteChannelMember"); appErr != nil {
+		return appErr
+	}
+
+	channel := a.channel(channelID)
+	if channel == nil || a.member(channelID, userID) == nil {
+		return notFound("DeleteChannelMember", channelID)
+	}
+	if channel.Name == model.DefaultChannelName {
+		return model.NewAppError("DeleteChannelMember", "api.channel.remove.default.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	var remaining []*model.ChannelMember
+	for _, member := range a.members[channelID] {
+		if member.UserId != userID {
+			remaining = append(remaining, member)
+		}
+	}
+	a.members[channelID] = remaining
+
+	for _, category := range a.sidebars[sidebarKey(userID, channel.TeamId)] {
+		category.Channels = without(category.Channels, channelID)
+	}
+
+	a.addPost(channelID, userID, model.PostTypeLeaveChannel, fmt.Sprintf("%s left the channel.", a.user(userID).Username))
+	return nil
+}
+
+// Sidebar
+
+func (a *API) GetChannelSidebarCategories(userID, teamID string) (*model.OrderedSidebarCategories, *model.AppError) {
+	defer a.enter("GetChannelSidebarCategories")()
+
+	categories := copyCategories(a.sidebar(userID, teamID))
+	ordered := &model.OrderedSidebarCategories{Categories: categories}
+	for _, category := range categories {
+		ordered.Order = append(ordered.Order, category.Id)
+	}
+	return ordered, nil
+}
+
+// CreateChannelSidebarCategory inserts the new category at the top of the
+// custom categories, moving its channels out of their previous categories.
+func (a *API) CreateChannelSidebarCategory(userID, teamID string, newCategory *model.SidebarCategoryWithChannels) (*model.SidebarCategoryWithChannels, *model.AppError) {
+	defer a.enter("CreateChannelSidebarCategory")()
+	if appErr := a.injected("CreateChannelSidebarCategory"); appErr != nil {
+		return nil, appErr
+	}
+
+	categories := a.sidebar(userID, teamID)
+
+	created := copyCategory(newCategory)
+	created.Id = model.NewId()
+	created.UserId = userID
+	created.TeamId = teamID
+	created.Type = model.SidebarCategoryCustom
+	a.moveChannels(categories, created)
+
+	position := 0
+	if len(categories) > 0 && categories[0].Type == model.SidebarCategoryFavorites {
+		position = 1
+	}
+	categories = append(categories[:position], append([]*model.SidebarCategoryWithChannels{created}, categories[position:]...)...)
+	a.setSidebar(userID, teamID, renumber(categories))
+
+	return copyCategory(created), nil
+}
+
+// UpdateChannelSidebarCategories replaces the given categories. A channel can
+// only be in one category, so channels are moved out of their previous ones.
+// Like the server, it ignores the sort order; only the order endpoint moves
+// categories.
+func (a *API) UpdateChannelSidebarCategories(userID, teamID string, categories []*model.SidebarCategoryWithChannels) ([]*model.SidebarCategoryWithChannels, *model.AppError) {
+	defer a.enter("UpdateChannelSidebarCategories")()
+	if appErr := a.injected("UpdateChannelSidebarCategories"); appErr != nil {
+		return nil, appErr
+	}
+
+	existing := a.sidebar(userID, teamID)
+	var updated []*model.SidebarCategoryWithChannels
+
+	for _, category := range categories {
+		index := categoryIndex(existing, category.Id)
+		if index < 0 {
+			return nil, notFound("UpdateChannelSidebarCategories", category.Id)
+		}
+
+		replacement := copyCategory(category)
+		replacement.UserId = userID
+		replacement.TeamId = teamID
+		replacement.Type = existing[index].Type
+		replacement.SortOrder = existing[index].SortOrder
+		if replacement.Type != model.SidebarCategoryCustom {
+			replacement.DisplayName = existing[index].DisplayName
+		}
+
+		a.moveChannels(existing, replacement)
+		existing[index] = replacement
+		updated = append(updated, copyCategory(replacement))
+	}
+
+	a.setSidebar(userID, teamID, existing)
+
+	return updated, nil
+}
+
+// GetDirectChannel returns the direct channel of both users, creating it on
+// first use.
+func (a *API) GetDirectChannel(userID1, userID2 string) (*model.Channel, *model.AppError) {
+	defer a.enter("GetDirectChannel")()
+
+	name := model.GetDMNameFromIds(userID1, userID2)
+	for _, channel := range a.channels {
+		if channel.Type == model.ChannelTypeDirect && channel.Name == name {
+			return channel, nil
+		}
+	}
+
+	channel := &model.Channel{Id: model.NewId(), Name: name, Type: model.ChannelTypeDirect}
+	a.channels = append(a.channels, channel)
+	a.addMember(channel.Id, userID1)
+	a.addMember(channel.Id, userID2)
+	return channel, nil
+}
+
+// Posts
+
+func (a *API) CreatePost(post *model.Post) (*model.Post, *model.AppError) {
+	defer a.enter("CreatePost")()
+
+	if a.channel(post.ChannelId) == nil {
+		return nil, notFound("CreatePost", post.ChannelId)
+	}
+	created := a.addPost(post.ChannelId, post.UserId, post.Type, post.Message)
+	created.Props = post.Props
+	return created, nil
+}
+
+func (a *API) SendEphemeralPost(userID string, post *model.Post) *model.Post {
+	defer a.enter("SendEphemeralPost")()
+
+	sent := post.Clone()
+	sent.Id = model.NewId()
+	sent.CreateAt = model.GetMillis()
+	a.ephemeral[userID] = append(a.ephemeral[userID], sent)
+	return sent
+}
+
+func (a *API) GetPostsForChannel(channelID string, page, perPage int) (*model.PostList, *model.AppError) {
+	defer a.enter("GetPostsForChannel")()
+
+	posts := a.posts[channelID]
+	var newestFirst []*model.Post
+	for i := len(posts) - 1; i >= 0; i-- {
+		newestFirst = append(newestFirst, posts[i])
+	}
+
+	list := model.NewPostList()
+	for _, post := range pageOf(newestFirst, page, perPage) {
+		list.AddPost(post)
+		list.AddOrder(post.Id)
+	}
+	return list, nil
+}
+
+// GetPostsSince lists the posts of the channel updated after the time, in
+// milliseconds, with the roots of their threads, newest first, as the server
+// does.
+func (a *API) GetPostsSince(channelID string, time int64) (*model.PostList, *model.AppError) {
+	defer a.enter("GetPostsSince")()
+
+	since := make(map[string]bool)
+	posts := a.posts[channelID]
+	for _, post := range posts {
+		if post.UpdateAt > time {
+			since[post.Id] = true
+			if post.RootId != "" {
+				since[post.RootId] = true
+			}
+		}
+	}
+
+	list := model.NewPostList()
+	for i := len(posts) - 1; i >= 0; i-- {
+		if since[posts[i].Id] {
+			list.AddPost(posts[i])
+			list.AddOrder(posts[i].Id)
+		}
+	}
+	return list, nil
+}
+
+func (a *API) DeletePost(postID string) *model.AppError {
+	defer a.enter("DeletePost")()
+
+	for channelID, posts := range a.posts {
+		for i, post := range posts {
+			if post.Id == postID {
+				a.posts[channelID] = append(posts[:i], posts[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return notFound("DeletePost", postID)
+}
+
+// Files
+
+func (a *API) GetFileInfo(fileID string) (*model.FileInfo, *model.AppError) {
+	defer a.enter("GetFileInfo")()
+	if info, found := a.files[fileID]; found {
+		return info, nil
+	}
+	return nil, notFound("GetFileInfo", fileID)
+}
+
+func (a *API) GetFile(fileID string) ([]byte, *model.AppError) {
+	defer a.enter("GetFile")()
+	if data, found := a.fileData[fileID]; found {
+		return data, nil
+	}
+	return nil, notFound("GetFile", fileID)
+}
+
+// KV store
+
+func (a *API) KVGet(key string) ([]byte, *model.AppError) {
+	defer a.enter("KVGet")()
+	return a.kv[key], nil
+}
+
+func (a *API) KVSet(key string, value []byte) *model.AppError {
+	defer a.enter("KVSet")()
+	a.kv[key] = value
+	return nil
+}
+
+// KVSetWithOptions supports plain and compare-and-set writes; expiry is
+// ignored.
+func (a *API) KVSetWithOptions(key string, value []byte, options model.PluginKVSetOptions) (bool, *model.AppError) {
+	defer a.enter("KVSetWithOptions")()
+
+	if options.Atomic && string(a.kv[key]) != string(options.OldValue) {
+		return false, nil
+	}
+	if value == nil {
+		delete(a.kv, key)
+	} else {
+		a.kv[key] = value
+	}
+	return true, nil
+}
+
+func (a *API) KVDelete(key string) *model.AppError {
+	defer a.enter("KVDelete")()
+	delete(a.kv, key)
+	return nil
+}
+
+// Configuration
+
+func (a *API) GetConfig() *model.Config {
+	defer a.enter("GetConfig")()
+	return a.config.Clone()
+}
+
+func (a *API) LoadPluginConfiguration(dest interface{}) error {
+	defer a.enter("LoadPluginConfiguration")()
+
+	data, err := json.Marshal(a.pluginConfig)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// Logging
+
+func (a *API) LogDebug(msg string, keyValuePairs ...interface{}) { a.log("debug", msg, keyValuePairs) }
+func (a *API) LogInfo(msg string, keyValuePairs ...interface{})  { a.log("info", msg, keyValuePairs) }
+func (a *API) LogWarn(msg string, keyValuePairs ...interface{})  { a.log("warn", msg, keyValuePairs) }
+func (a *API) LogError(msg string, keyValuePairs ...interface{}) { a.log("error", msg, keyValuePairs) }
+
+// private
+
+// enter locks the fake for the duration of an API call and counts the call.
+func (a *API) enter(method string) func() {
+	a.mu.Lock()
+	a.calls[method]++
+	return a.mu.Unlock
+}
+
+func (a *API) log(level, msg string, keyValuePairs []interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.Logs = append(a.Logs, fmt.Sprintf("%s: %s %v", level, msg, keyValuePairs))
+}
+
+func (a *API) teamByName(name string) (*model.Team, *model.AppError) {
+	for _, team := range a.teams {
+		if team.Name == name {
+			return team, nil
+		}
+	}
+	return nil, notFound("GetTeamByName", name)
+}
+
+func (a *API) user(userID string) *model.User {
+	for _, user := range a.users {
+		if user.Id == userID {
+			return user
+		}
+	}
+	return nil
+}
+
+func (a *API) channel(channelID string) *model.Channel {
+	for _, channel := range a.channels {
+		if channel.Id == channelID {
+			return channel
+		}
+	}
+	return nil
+}
+
+func (a *API) member(channelID, userID string) *model.ChannelMember {
+	for _, member := range a.members[channelID] {
+		if member.UserId == userID {
+			return member
+		}
+	}
+	return nil
+}
+
+func (a *API) addMember(channelID, userID string) *model.ChannelMember {
+	member := &model.ChannelMember{ChannelId: channelID, UserId: userID, NotifyProps: model.GetDefaultChannelNotifyProps()}
+	a.members[channelID] = append(a.members[channelID], member)
+
+	// New channels show up in the "Channels" category of sidebars already in use
+	if channel := a.channel(channelID); channel != nil {
+		if categories, exists := a.sidebars[sidebarKey(userID, channel.TeamId)]; exists {
+			for _, category := range categories {
+				if category.Type == model.SidebarCategoryChannels {
+					category.Channels = append(category.Channels, channelID)
+				}
+			}
+		}
+	}
+	return member
+}
+
+// joinChannel adds the user to the channel, then posts the membership message
+// through the post hooks, outside of the lock, as the hooks call back.
+func (a *API) joinChannel(method, channelID, userID, actorID string) (*model.ChannelMember, *model.AppError) {
+	member, post, appErr := a.addChannelMember(method, channelID, userID, actorID)
+	if appErr != nil || post == nil {
+		return member, appErr
+	}
+
+	if a.postHooks != nil {
+		var rejection string
+		if post, rejection = a.postHooks.MessageWillBePosted(nil, post); post == nil || rejection != "" {
+			return member, nil
+		}
+	}
+
+	a.mu.Lock()
+	a.storePost(post)
+	a.mu.Unlock()
+
+	if a.postHooks != nil {
+		a.postHooks.MessageHasBeenPosted(nil, post)
+	}
+	return member, nil
+}
+
+// addChannelMember adds the user and returns the membership message to post,
+// which is nil if the user was a member already.
+func (a *API) addChannelMember(method, channelID, userID, actorID string) (*model.ChannelMember, *model.Post, *model.AppError) {
+	defer a.enter(method)()
+	if appErr := a.injected(method); appErr != nil {
+		return nil, nil, appErr
+	}
+
+	if a.channel(channelID) == nil {
+		return nil, nil, notFound(method, channelID)
+	}
+	user := a.user(userID)
+	if user == nil {
+		return nil, nil, notFound(method, userID)
+	}
+	if member := a.member(channelID, userID); member != nil {
+		return member, nil, nil
+	}
+
+	member := a.addMember(channelID, userID)
+
+	var post *model.Post
+	if actor := a.user(actorID); actor != nil && actorID != userID {
+		post = a.newPost(channelID, actorID, model.PostTypeAddToChannel, fmt.Sprintf("%s added to the channel by %s.", user.Username, actor.Username))
+		post.AddProp(model.PostPropsAddedUserId, userID)
+	} else {
+		post = a.newPost(channelID, userID, model.PostTypeJoinChannel, fmt.Sprintf("%s joined the channel.", user.Username))
+	}
+	return member, post, nil
+}
+
+func (a *API) addPost(channelID, userID, postType, message string) *model.Post {
+	post := a.newPost(channelID, userID, postType, message)
+	a.storePost(post)
+	return post
+}
+
+func (a *API) newPost(channelID, userID, postType, message string) *model.Post {
+	return &model.Post{
+		Id:        model.NewId(),
+		ChannelId: channelID,
+		UserId:    userID,
+		Type:      postType,
+		Message:   message,
+	}
+}
+
+func (a *API) storePost(post *model.Post) {
+	post.CreateAt = model.GetMillis() + int64(len(a.posts[post.ChannelId]))
+	post.UpdateAt = post.CreateAt
+	a.posts[post.ChannelId] = append(a.posts[post.ChannelId], post)
+}
+
+// sidebar returns the categories of the user, creating the default ones with
+// all channels of the user in "Channels" on first use, as the server does.
+func (a *API) sidebar(userID, teamID string) []*model.SidebarCategoryWithChannels {
+	key := sidebarKey(userID, teamID)
+	if categories, exists := a.sidebars[key]; exists {
+		return categories
+	}
+
+	newCategory := func(categoryType model.SidebarCategoryType, displayName string) *model.SidebarCategoryWithChannels {
+		return &model.SidebarCategoryWithChannels{
+			SidebarCategory: model.SidebarCategory{
+				Id:          model.NewId(),
+				UserId:      userID,
+				TeamId:      teamID,
+				Type:        categoryType,
+				DisplayName: displayName,
+				Sorting:     model.SidebarCategorySortDefault,
+			},
+			Channels: []string{},
+		}
+	}
+
+	channels := newCategory(model.SidebarCategoryChannels, "Channels")
+	for _, channel := range a.channels {
+		if channel.TeamId == teamID && a.member(channel.Id, userID) != nil {
+			channels.Channels = append(channels.Channels, channel.Id)
+		}
+	}
+
+	categories := renumber([]*model.SidebarCategoryWithChannels{
+		newCategory(model.SidebarCategoryFavorites, "Favorites"),
+		channels,
+		newCategory(model.SidebarCategoryDirectMessages, "Direct Messages"),
+	})
+	a.sidebars[key] = categories
+	return categories
+}
+
+func (a *API) setSidebar(userID, teamID string, categories []*model.SidebarCategoryWithChannels) {
+	a.sidebars[sidebarKey(userID, teamID)] = categories
+}
+
+// moveChannels removes the channels of the category from all other categories.
+func (a *API) moveChannels(categories []*model.SidebarCategoryWithChannels, category *model.SidebarCategoryWithChannels) {
+	moved := make(map[string]bool)
+	for _, channelID := range category.Channels {
+		moved[channelID] = true
+	}
+
+	for _, other := range categories {
+		if other.Id == category.Id {
+			continue
+		}
+		kept := []string{}
+		for _, channelID := range other.Channels {
+			if !moved[channelID] {
+				kept = append(kept, channelID)
+			}
+		}
+		other.Channels = kept
+	}
+}
+
+func without(channelIDs []string, channelID string) []string {
+	kept := []string{}
+	for _, id := range channelIDs {
+		if id != channelID {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+func sidebarKey(userID, teamID string) string {
+	return userID + "/" + teamID
+}
+
+func categoryIndex(categories []*model.SidebarCategoryWithChannels, categoryID string) int {
+	for i, category := range categories {
+		if category.Id == categoryID {
+			return i
+		}
+	}
+	return -1
+}
+
+func renumber(categories []*model.SidebarCategoryWithChannels) []*model.SidebarCategoryWithChannels {
+	for i, category := range categories {
+		category.SortOrder = int64(i * 10)
+	}
+	return categories
+}
+
+func copyCategory(category *model.SidebarCategoryWithChannels) *model.SidebarCategoryWithChannels {
+	return &model.SidebarCategoryWithChannels{
+		SidebarCategory: category.SidebarCategory,
+		Channels:        append([]string{}, category.Channels...),
+	}
+}
+
+func copyCategories(categories []*model.SidebarCategoryWithChannels) []*model.SidebarCategoryWithChannels {
+	var copies []*model.SidebarCategoryWithChannels
+	for _, category := range categories {
+		copies = append(copies, copyCategory(category))
+	}
+	return copies
+}
+
+func pageOf[T any](items []T, page, perPage int) []T {
+	start := page * perPage
+	if start >= len(items) {
+		return nil
+	}
+	end := start + perPage
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
+
+// injected returns the next failure queued for the method by Fail, if any.
+func (a *API) injected(method string) *model.AppError {
+	statusCodes := a.failures[method]
+	if len(statusCodes) == 0 {
+		return nil
+	}
+	a.failures[method] = statusCodes[1:]
+	if statusCodes[0] == http.StatusOK {
+		return nil
+	}
+	return model.NewAppError(method, "app.fake.injected", nil, "", statusCodes[0])
+}
+
+func notFound(where, id string) *model.AppError {
+	return model.NewAppError(where, "app.fake.not_found", nil, "id="+id, http.StatusNotFound)
+}
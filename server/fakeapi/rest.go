@@ -0,0 +1,98 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/glass.plugin-anchor/server/api"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"net/http"
+	"strings"
+)
+
+// The fake doubles as the REST client for the sidebar endpoints the plugin API
+// lacks: deleting a category and setting the category order.
+
+func (a *API) Get(path string) ([]byte, error) {
+	defer a.enter("REST GET")()
+	return nil, fmt.Errorf("GET %s is not supported by the fake", path)
+}
+
+func (a *API) Post(path string, _ interface{}) ([]byte, error) {
+	defer a.enter("REST POST")()
+	return nil, fmt.Errorf("POST %s is not supported by the fake", path)
+}
+
+// Delete handles users/{user}/teams/{team}/channels/categories/{category}. The
+// channels of the deleted category go back to "Channels".
+func (a *API) Delete(path string) ([]byte, error) {
+	defer a.enter("REST DELETE")()
+	if appErr := a.injected("REST DELETE"); appErr != nil {
+		return nil, &api.StatusError{StatusCode: appErr.StatusCode}
+	}
+
+	userID, teamID, categoryID, ok := parseCategoryPath(path)
+	if !ok || categoryID == "order" {
+		return nil, fmt.Errorf("DELETE %s is not supported by the fake", path)
+	}
+
+	categories := a.sidebar(userID, teamID)
+	index := categoryIndex(categories, categoryID)
+	if index < 0 {
+		return nil, &api.StatusError{StatusCode: http.StatusNotFound}
+	}
+
+	deleted := categories[index]
+	if deleted.Type != model.SidebarCategoryCustom {
+		return nil, &api.StatusError{StatusCode: http.StatusBadRequest}
+	}
+
+	categories = append(categories[:index], categories[index+1:]...)
+	for _, category := range categories {
+		if category.Type == model.SidebarCategoryChannels {
+			category.Channels = append(category.Channels, deleted.Channels...)
+		}
+	}
+	a.setSidebar(userID, teamID, categories)
+
+	return json.Marshal(map[string]string{"status": "OK"})
+}
+
+// Put handles users/{user}/teams/{team}/channels/categories/order, which must
+// list every category of the sidebar exactly once.
+func (a *API) Put(path string, data interface{}) ([]byte, error) {
+	defer a.enter("REST PUT")()
+	if appErr := a.injected("REST PUT"); appErr != nil {
+		return nil, &api.StatusError{StatusCode: appErr.StatusCode}
+	}
+
+	userID, teamID, endpoint, ok := parseCategoryPath(path)
+	if !ok || endpoint != "order" {
+		return nil, fmt.Errorf("PUT %s is not supported by the fake", path)
+	}
+
+	order, ok := data.([]string)
+	categories := a.sidebar(userID, teamID)
+	if !ok || len(order) != len(categories) {
+		return nil, &api.StatusError{StatusCode: http.StatusBadRequest}
+	}
+
+	var reordered []*model.SidebarCategoryWithChannels
+	for _, categoryID := range order {
+		index := categoryIndex(categories, categoryID)
+		if index < 0 {
+			return nil, &api.StatusError{StatusCode: http.StatusBadRequest}
+		}
+		reordered = append(reordered, categories[index])
+	}
+	a.setSidebar(userID, teamID, renumber(reordered))
+
+	return json.Marshal(order)
+}
+
+func parseCategoryPath(path string) (userID, teamID, last string, ok bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 7 || parts[0] != "users" || parts[2] != "teams" || parts[4] != "channels" || parts[5] != "categories" {
+		return "", "", "", false
+	}
+	return parts[1], parts[3], parts[6], true
+}
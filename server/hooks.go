@@ -1,6 +1,8 @@
 package main
 
 import (
+	"github.com/glass.plugin-anchor/server/business"
+	"github.com/glass.plugin-anchor/server/config"
 	"github.com/mattermost/mattermost-server/v6/model"
 	"github.com/mattermost/mattermost-server/v6/plugin"
 )
@@ -26,8 +28,11 @@ func (p *AnchorPlugin) UserHasJoinedChannel(c *plugin.Context, channelMember *mo
 			return
 		}
 
-		// Subscribe the user to the "Follower" channel
-		_, appErr = p.API.AddChannelMember(followerChannel.Id, user.Id)
+		// Have the bot subscribe the user to the "Follower" channel
+		if err := business.ExpectMembershipPost(p.NewHookContext(nil, nil), followerChannel.Id, user.Id); err != nil {
+			p.API.LogWarn("Membership message will be posted", "channel_id", followerChannel.Id, "error", err.Error())
+		}
+		_, appErr = p.API.AddUserToChannel(followerChannel.Id, user.Id, p.botID)
 		if appErr != nil {
 			p.API.LogError("Failed to add user to Follower channel", "user_id", user.Id, "channel_id", followerChannel.Id, "error", appErr.Error())
 			return
@@ -37,3 +42,81 @@ func (p *AnchorPlugin) UserHasJoinedChannel(c *plugin.Context, channelMember *mo
 
 	}
 }
+
+// UserHasLeftTeam offboards the leaving member if config.OffboardOnLeave is set.
+// The server already removed them from the channels of the team they left, so
+// this deletes their categories there and removes them from the private
+// channels of their other teams.
+func (p *AnchorPlugin) UserHasLeftTeam(c *plugin.Context, teamMember *model.TeamMember, actor *model.User) {
+	if !config.OffboardOnLeave {
+		return
+	}
+
+	team, appErr := p.API.GetTeam(teamMember.TeamId)
+	if appErr != nil {
+		p.API.LogError("Failed to get team", "team_id", teamMember.TeamId, "error", appErr.Error())
+		return
+	}
+	user, appErr := p.API.GetUser(teamMember.UserId)
+	if appErr != nil {
+		p.API.LogError("Failed to get user", "user_id", teamMember.UserId, "error", appErr.Error())
+		return
+	}
+
+	actorName := user.Username
+	if actor != nil {
+		actorName = actor.Username
+	}
+
+	sideBar, err := business.NewSideBar(business.WrapUser(p.NewHookContext(team, actor), user))
+	if err != nil {
+		p.API.LogError("Failed to offboard user", "user", user.Username, "error", err.Error())
+		return
+	}
+
+	result, err := sideBar.Offboard(actorName, config.DeactivateOnLeave)
+	if err != nil {
+		p.API.LogError("Failed to offboard user", "user", user.Username, "error", err.Error())
+	}
+	if result != nil {
+		p.API.LogInfo("Offboarded user", "user", user.Username, "channels", len(result.RemovedChannels), "categories", len(result.DeletedCategories), "deactivated", result.Deactivated)
+	}
+}
+
+// MessageWillBePosted drops the membership messages caused by the plugin if
+// config.SuppressMembershipPosts is set.
+func (p *AnchorPlugin) MessageWillBePosted(c *plugin.Context, post *model.Post) (*model.Post, string) {
+	if !config.SuppressMembershipPosts || !business.IsMembershipPost(post) {
+		return post, ""
+	}
+
+	expected, err := business.TakeExpectedMembershipPost(p.NewHookContext(nil, nil), post)
+	if err != nil {
+		p.API.LogError("Failed to look up membership message", "channel_id", post.ChannelId, "error", err.Error())
+	}
+	if !expected {
+		return post, ""
+	}
+	return nil, "membership message suppressed by the anchor plugin"
+}
+
+// MessageHasBeenPosted queues the membership messages caused by the plugin
+// that were posted anyway for cleanup.
+func (p *AnchorPlugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+	if !business.IsMembershipPost(post) {
+		return
+	}
+
+	hookContext := p.NewHookContext(nil, nil)
+	expected, err := business.TakeExpectedMembershipPost(hookContext, post)
+	if err != nil {
+		p.API.LogError("Failed to look up membership message", "channel_id", post.ChannelId, "error", err.Error())
+		return
+	}
+	if !expected {
+		return
+	}
+	if err := business.QueueCleanup(hookContext, post); err != nil {
+		p.API.LogError("Failed to queue membership message for cleanup", "post_id", post.Id, "error", err.Error())
+	}
+}
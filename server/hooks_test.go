@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/glass.plugin-anchor/server/fakeapi"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"strings"
+	"testing"
+)
+
+func TestUserHasJoinedChannel(t *testing.T) {
+	tests := []struct {
+		name           string
+		joined         string
+		followerMember bool
+	}{
+		{"joining master subscribes to follower", "master", true},
+		{"joining another channel does nothing", "other", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := fakeapi.New()
+			team := api.AddTeam("lbw")
+			user := api.AddUser("skipper", model.SystemUserRoleId)
+			joined := api.AddChannel(team.Id, test.joined, test.joined, model.ChannelTypeOpen)
+			follower := api.AddChannel(team.Id, "follower", "Follower", model.ChannelTypeOpen)
+			api.AddMember(joined.Id, user.Id)
+
+			p := &AnchorPlugin{}
+			p.SetAPI(api)
+			p.UserHasJoinedChannel(nil, &model.ChannelMember{ChannelId: joined.Id, UserId: user.Id}, user)
+
+			if member := api.IsMember(follower.Id, user.Id); member != test.followerMember {
+				t.Errorf("member of follower = %t, want %t", member, test.followerMember)
+			}
+		})
+	}
+}
+
+func TestUserHasLeftTeamIsOptional(t *testing.T) {
+	api := fakeapi.New()
+	team := api.AddTeam("esc")
+	user := api.AddUser("skipper", model.SystemUserRoleId)
+	channel := api.AddChannel(team.Id, "club-news", "Club News", model.ChannelTypeOpen)
+	api.AddMember(channel.Id, user.Id)
+
+	p := &AnchorPlugin{}
+	p.SetAPI(api)
+	p.UserHasLeftTeam(nil, &model.TeamMember{TeamId: team.Id, UserId: user.Id}, nil)
+
+	if !api.IsMember(channel.Id, user.Id) {
+		t.Errorf("user was offboarded although OffboardOnLeave is off")
+	}
+}
+
+func TestMembershipPostsOfOnboarding(t *testing.T) {
+	tests := []struct {
+		name     string
+		suppress bool
+		posted   int
+		queued   int // skipper joins Town Square and Club News
+	}{
+		{"suppressed", true, 0, 0},
+		{"queued for cleanup", false, 1, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(suppress bool) { config.SuppressMembershipPosts = suppress }(config.SuppressMembershipPosts)
+			config.SuppressMembershipPosts = test.suppress
+
+			p, api := newTestPlugin(model.SystemAdminRoleId + " " + model.SystemUserRoleId)
+			api.SetPostHooks(p)
+			channel := api.AddChannel(p.Context.Team.Id, "club-news", "Club News", model.ChannelTypeOpen)
+			api.AddPost(channel.Id, p.Context.User.Id, model.PostTypeDefault, "The club house opens at ten.")
+
+			p.GetCommandResponse(nil, "/anchor onboard skipper")
+
+			if posts := api.Posts(channel.Id); len(posts) != 1+test.posted {
+				t.Fatalf("%d posts in Club News, want %d membership messages", len(posts), test.posted)
+			}
+
+			response := p.GetCommandResponse(nil, "/anchor cleanup_queued")
+			if expected := fmt.Sprintf("Deleted %d of %d", test.queued, test.queued); !strings.Contains(response, expected) {
+				t.Errorf("response = %q, want %q", response, expected)
+			}
+			if posts := api.Posts(channel.Id); len(posts) != 1 || posts[0].Type != model.PostTypeDefault {
+				t.Errorf("posts after cleanup = %v, want the regular post", posts)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/glass.plugin-anchor/server/business"
+	"github.com/mattermost/mattermost-server/v6/plugin"
+	"net/http"
+	"strings"
+)
+
+// ServeHTTP serves the plugin API to system admins:
+//
+//	GET /api/v1/teams/{team_id}/stats   compliance statistics of the team
+func (p *AnchorPlugin) ServeHTTP(_ *plugin.Context, w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 5 || parts[0] != "api" || parts[1] != "v1" || parts[2] != "teams" || parts[4] != "stats" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p.serveStats(w, userID, parts[3])
+}
+
+// private
+
+func (p *AnchorPlugin) serveStats(w http.ResponseWriter, userID, teamID string) {
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		writeError(w, business.NewError(business.KindPermission, "get stats", teamID, appErr))
+		return
+	}
+	if !user.IsSystemAdmin() {
+		writeError(w, business.NewError(business.KindPermission, "get stats", teamID, nil))
+		return
+	}
+
+	team, appErr := p.API.GetTeam(teamID)
+	if appErr != nil {
+		writeError(w, business.NewError(business.KindNotFound, "get team", teamID, appErr))
+		return
+	}
+
+	c := p.NewHookContext(team, user)
+	stats, err := business.WrapTeam(c, team).ComplianceStats()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		p.API.LogError("Failed to write stats", "team", team.Name, "error", err.Error())
+	}
+}
+
+// writeError answers with the status code matching the kind of the error.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch business.KindOf(err) {
+	case business.KindPermission:
+		status = http.StatusForbidden
+	case business.KindNotFound:
+		status = http.StatusNotFound
+	case business.KindInvalid:
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
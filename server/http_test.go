@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/glass.plugin-anchor/server/business"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeStats(t *testing.T) {
+	p, api := newTestPlugin(model.SystemAdminRoleId + " " + model.SystemUserRoleId)
+	skipper, _ := api.GetUserByUsername("skipper")
+	statsPath := "/api/v1/teams/" + p.Context.Team.Id + "/stats"
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		userID string
+		status int
+	}{
+		{"stats", http.MethodGet, statsPath, p.Context.User.Id, http.StatusOK},
+		{"requires a user", http.MethodGet, statsPath, "", http.StatusUnauthorized},
+		{"requires system admin", http.MethodGet, statsPath, skipper.Id, http.StatusForbidden},
+		{"unknown team", http.MethodGet, "/api/v1/teams/nowhere/stats", p.Context.User.Id, http.StatusNotFound},
+		{"unknown path", http.MethodGet, "/api/v1/sail", p.Context.User.Id, http.StatusNotFound},
+		{"only GET", http.MethodPost, statsPath, p.Context.User.Id, http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.path, nil)
+			if test.userID != "" {
+				r.Header.Set("Mattermost-User-Id", test.userID)
+			}
+			w := httptest.NewRecorder()
+
+			p.ServeHTTP(nil, w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+			var stats business.ComplianceStats
+			if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+				t.Fatal(err)
+			}
+			if stats.Users != 2 {
+				t.Errorf("stats of %d users, want the 2 members", stats.Users)
+			}
+		})
+	}
+}
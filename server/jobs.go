@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/glass.plugin-anchor/server/models"
+	"github.com/mattermost/mattermost-server/v6/model"
+)
+
+// runJob runs a long command in the background, so that the command returns
+// at once, and has the bot send the report to the admin who started it. Only
+// one job of a name runs at a time; runJob returns false if it is running.
+func (p *AnchorPlugin) runJob(name string, c *models.Context, run func(c *models.Context) string) bool {
+	p.jobsLock.Lock()
+	defer p.jobsLock.Unlock()
+
+	if p.jobs == nil {
+		p.jobs = make(map[string]bool)
+	}
+	if p.jobs[name] {
+		return false
+	}
+	p.jobs[name] = true
+
+	// The context of the plugin is reused by the next command
+	jobContext := *c
+
+	p.jobsDone.Add(1)
+	go func() {
+		defer p.jobsDone.Done()
+		defer func() {
+			p.jobsLock.Lock()
+			delete(p.jobs, name)
+			p.jobsLock.Unlock()
+		}()
+
+		p.report(jobContext.User.Id, run(&jobContext))
+	}()
+	return true
+}
+
+// report sends the message to the user as a direct message from the bot.
+func (p *AnchorPlugin) report(userID string, message string) {
+	if p.botID == "" {
+		p.API.LogWarn("No bot to send the report", "user_id", userID, "report", message)
+		return
+	}
+
+	channel, appErr := p.API.GetDirectChannel(p.botID, userID)
+	if appErr != nil {
+		p.API.LogError("Failed to open direct channel for the report", "user_id", userID, "error", appErr.Error())
+		return
+	}
+	if _, appErr := p.API.CreatePost(&model.Post{UserId: p.botID, ChannelId: channel.Id, Message: message}); appErr != nil {
+		p.API.LogError("Failed to send the report", "user_id", userID, "error", appErr.Error())
+	}
+}
@@ -20,12 +20,6 @@ type Context struct {
 	API  plugin.API
 	Rest RestAPI
 
-	Auth Auth
-}
-
-type Auth struct {
-	AuthToken   string
-	SQLPassword string
-	SystemToken string
-	BotToken    string
+	// BotID is the user ID of the plugin's bot, which sends its messages
+	BotID string
 }
@@ -3,16 +3,27 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/glass.plugin-anchor/server/config"
 	"github.com/glass.plugin-anchor/server/models"
 	"github.com/mattermost/mattermost-server/v6/model"
 	"github.com/mattermost/mattermost-server/v6/plugin"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 type AnchorPlugin struct {
 	plugin.MattermostPlugin
 	Context *models.Context
+
+	botID string
+
+	configurationLock sync.RWMutex
+	configuration     *configuration
+
+	jobsLock sync.Mutex
+	jobs     map[string]bool // names of the running jobs
+	jobsDone sync.WaitGroup
 }
 
 type PluginManifest struct {
@@ -41,6 +52,12 @@ func (p *AnchorPlugin) OnActivate() error {
 		}
 	}
 
+	botID, err := p.ensureBot()
+	if err != nil {
+		return fmt.Errorf("failed to ensure bot %s: %w", config.BotUsername, err)
+	}
+	p.botID = botID
+
 	return nil
 }
 
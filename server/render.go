@@ -0,0 +1,469 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/glass.plugin-anchor/server/business"
+	"github.com/glass.plugin-anchor/server/config"
+	"github.com/mattermost/mattermost-server/v6/model"
+	"sort"
+	"strings"
+	"time"
+)
+
+// renderError turns an error into a message telling the admin what to do
+// about it, depending on its kind.
+func renderError(err error) string {
+	if errors.Is(err, errNotAdmin) {
+		return "You do not have permission to execute this command."
+	}
+
+	switch business.KindOf(err) {
+	case business.KindPermission:
+		return "**Permission denied:** " + err.Error()
+	case business.KindNotFound:
+		return "**Not found:** " + err.Error()
+	case business.KindInvalid:
+		return "**Invalid:** " + err.Error()
+	case business.KindPartial:
+		items := business.ItemErrors(err)
+		lines := []string{fmt.Sprintf("**%d failed:**", len(items))}
+		for _, item := range items {
+			lines = append(lines, fmt.Sprintf("- %s (%s)", item.Error(), item.Kind))
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return "**Mattermost API failure:** " + err.Error()
+	}
+}
+
+// withError appends the rendering of a partial failure to the result.
+func withError(result string, err error) string {
+	if err == nil {
+		return result
+	}
+	return strings.Join([]string{result, renderError(err)}, "\n")
+}
+
+func renderNames(names []string, none string) string {
+	if len(names) == 0 {
+		return none
+	}
+	return strings.Join(names, "\n")
+}
+
+func renderUsers(users []*model.User) string {
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	return renderNames(names, "No users")
+}
+
+func renderTeams(teams []*model.Team) string {
+	var names []string
+	for _, team := range teams {
+		names = append(names, team.Name)
+	}
+	return renderNames(names, "No teams")
+}
+
+func renderChannels(channels []*model.Channel) string {
+	var names []string
+	for _, channel := range channels {
+		names = append(names, channel.DisplayName)
+	}
+	return renderNames(names, "No channels")
+}
+
+func renderCleanup(result *business.CleanupResult) string {
+	switch {
+	case result.Pattern == "" && result.DryRun:
+		return fmt.Sprintf("%d membership messages are queued, none deleted (dry run).", len(result.Matched))
+	case result.Pattern == "":
+		return fmt.Sprintf("Deleted %d of %d queued membership messages.", result.Deleted, len(result.Matched))
+	case result.DryRun:
+		return fmt.Sprintf("%d posts match `%s`, none deleted (dry run).", len(result.Matched), result.Pattern)
+	default:
+		return fmt.Sprintf("Deleted %d of %d posts matching `%s`.", result.Deleted, len(result.Matched), result.Pattern)
+	}
+}
+
+func renderTeamCleanup(team string, result *business.TeamCleanupResult) string {
+	matched, deleted := result.Totals()
+
+	var lines []string
+	if result.DryRun {
+		lines = append(lines, fmt.Sprintf("Cleanup of **%s**: %d posts match `%s` in %d channels, none deleted (dry run).", team, matched, result.Pattern, len(result.Channels)))
+	} else {
+		lines = append(lines, fmt.Sprintf("Cleanup of **%s**: deleted %d of %d posts matching `%s` in %d channels.", team, deleted, matched, result.Pattern, len(result.Channels)))
+	}
+
+	for _, channel := range result.Channels {
+		if channel.Matched == 0 {
+			continue
+		}
+		if result.DryRun {
+			lines = append(lines, fmt.Sprintf("- %s: %d", channel.Channel, channel.Matched))
+		} else {
+			lines = append(lines, fmt.Sprintf("- %s: %d of %d", channel.Channel, channel.Deleted, channel.Matched))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderStructureReport(report *business.StructureReport) string {
+	lines := []string{fmt.Sprintf("User: **%s** (%s):", report.User.Username, report.User.GetFullName())}
+
+	if report.Compliant() && len(report.UnresolvedChannels) == 0 {
+		return strings.Join(append(lines, "."), "\n")
+	}
+	if len(report.MissingCategories) > 0 {
+		lines = append(lines, "Missing required categories: "+strings.Join(report.MissingCategories, ", "))
+	}
+	if len(report.MissingChannels) > 0 {
+		lines = append(lines, "Missing required channels: "+strings.Join(report.MissingChannels, ", "))
+	}
+	if len(report.UnresolvedChannels) > 0 {
+		lines = append(lines, "Unresolved channels: "+strings.Join(report.UnresolvedChannels, ", "))
+	}
+	if len(report.WronglyCategorized) > 0 {
+		var channels []string
+		for _, m := range report.WronglyCategorized {
+			channels = append(channels, fmt.Sprintf("%s (expected: %s, got: %s)", m.Channel, m.Expected, m.Actual))
+		}
+		lines = append(lines, "Wrongly categorized channels: "+strings.Join(channels, ", "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderStructureReports(reports []*business.StructureReport) string {
+	var rendered []string
+	for _, report := range reports {
+		rendered = append(rendered, renderStructureReport(report))
+	}
+	return strings.Join(rendered, "\n\n")
+}
+
+func renderActivity(days int, activities []business.CategoryActivity) string {
+	lines := []string{fmt.Sprintf("Activity of the last %d days:", days)}
+
+	for _, activity := range activities {
+		lines = append(lines, "\n**"+activity.Category+":**")
+		for _, channel := range activity.Channels {
+			last := "never"
+			if !channel.LastActivity.IsZero() {
+				last = channel.LastActivity.UTC().Format("2006-01-02")
+			}
+			lines = append(lines, fmt.Sprintf("- %s: %d posts by %d members, last activity %s", channel.Channel, channel.Posts, channel.Posters, last))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderArchived(archived []string) string {
+	if config.ArchiveEndedChannels {
+		return "Archived channels: " + renderList(archived)
+	}
+	return "Moved ended channels to " + config.ArchiveCategory + " for: " + renderList(archived)
+}
+
+func renderSeason(result *business.SeasonResult) string {
+	var usernames []string
+	for username := range result.Joined {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+
+	lines := []string{"Joined the active channels:"}
+	if len(usernames) == 0 {
+		lines = []string{"No active channels to join."}
+	}
+	for _, username := range usernames {
+		lines = append(lines, fmt.Sprintf("- %s: %s", username, strings.Join(result.Joined[username], ", ")))
+	}
+
+	return strings.Join(append(lines, renderArchived(result.Archived)), "\n")
+}
+
+func renderStats(stats *business.ComplianceStats) string {
+	lines := []string{fmt.Sprintf("%d of %d members are compliant (**%.0f%%**).", stats.Compliant, stats.Users, stats.CompliantPercent())}
+
+	if len(stats.MissingChannels) > 0 {
+		lines = append(lines, "\n**Most often missing channels:**")
+		for _, count := range stats.MissingChannels {
+			lines = append(lines, fmt.Sprintf("- %s: %d", count.Name, count.Count))
+		}
+	}
+	if len(stats.MissingCategories) > 0 {
+		lines = append(lines, "\n**Most often missing categories:**")
+		for _, count := range stats.MissingCategories {
+			lines = append(lines, fmt.Sprintf("- %s: %d", count.Name, count.Count))
+		}
+	}
+	if len(stats.Drift) > 0 {
+		lines = append(lines, "\n**Largest drift:**")
+		for _, drift := range stats.Drift {
+			lines = append(lines, fmt.Sprintf("- %s: %d", drift.Username, drift.Drift))
+		}
+	}
+	if len(stats.Failed) > 0 {
+		lines = append(lines, fmt.Sprintf("\n**%d checks failed:**", len(stats.Failed)))
+		for _, failure := range stats.Failed {
+			lines = append(lines, "- "+failure)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderOnboarding(result *business.OnboardingResult) string {
+	var lines []string
+
+	for _, step := range result.Steps {
+		line := fmt.Sprintf("Step %s: %s", step.Step, step.State)
+		if step.Attempts > 1 {
+			line += fmt.Sprintf(" after %d attempts", step.Attempts)
+		}
+		lines = append(lines, line)
+	}
+
+	if result.Join != nil {
+		for _, channel := range result.Join.Joined {
+			lines = append(lines, "Added user to channel: "+channel)
+		}
+		if len(result.Join.AlreadyMember) > 0 {
+			lines = append(lines, "Already a member of: "+strings.Join(result.Join.AlreadyMember, ", "))
+		}
+		if len(result.Join.Notified) > 0 {
+			lines = append(lines, "Preset notifications in: "+strings.Join(result.Join.Notified, ", "))
+		}
+	}
+	for _, assignment := range result.Assignments {
+		if len(assignment.Added) > 0 {
+			lines = append(lines, fmt.Sprintf("Added to category %s: %s", assignment.Category, strings.Join(assignment.Added, ", ")))
+		} else {
+			lines = append(lines, fmt.Sprintf("Category %s is complete", assignment.Category))
+		}
+	}
+	if result.Reorder != nil {
+		lines = append(lines, renderReorder(result.Reorder))
+	}
+	if result.Welcome != nil {
+		lines = append(lines, "Sent a welcome message.")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderTeamOnboardings(outcomes []business.TeamOnboarding) string {
+	if len(outcomes) == 0 {
+		return "The user is not a member of any team."
+	}
+
+	var rendered []string
+	for _, outcome := range outcomes {
+		section := "**Team " + outcome.Team.DisplayName + ":**"
+		if outcome.Result != nil {
+			section += "\n" + renderOnboarding(outcome.Result)
+		}
+		rendered = append(rendered, withError(section, outcome.Err))
+	}
+	return strings.Join(rendered, "\n\n")
+}
+
+func renderTeamStructureReports(reports []business.TeamStructureReport) string {
+	if len(reports) == 0 {
+		return "The user is not a member of any team."
+	}
+
+	var rendered []string
+	for _, report := range reports {
+		section := "**Team " + report.Team.DisplayName + ":**"
+		if report.Report != nil {
+			section += "\n" + renderStructureReport(report.Report)
+		}
+		rendered = append(rendered, withError(section, report.Err))
+	}
+	return strings.Join(rendered, "\n\n")
+}
+
+func renderNotifyDrifts(username string, drifts []business.NotifyDrift, reset bool) string {
+	if len(drifts) == 0 {
+		return fmt.Sprintf("The notification preferences of **%s** match the presets.", username)
+	}
+
+	lines := []string{fmt.Sprintf("Notification preferences of **%s** differing from the presets (add `reset` to reset them):", username)}
+	if reset {
+		lines[0] = fmt.Sprintf("Reset the notification preferences of **%s**:", username)
+	}
+
+	for _, drift := range drifts {
+		var keys, props []string
+		for key := range drift.Expected {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			props = append(props, fmt.Sprintf("%s %s (preset: %s)", key, drift.Actual[key], drift.Expected[key]))
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", drift.Channel, strings.Join(props, ", ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// renderReorder lists the categories in the order they got.
+func renderReorder(result *business.ReorderResult) string {
+	var names []string
+	for _, category := range result.After {
+		names = append(names, category.Category)
+	}
+	return "Category order: " + strings.Join(names, ", ")
+}
+
+func renderSidebarPlan(username string, plan *business.SidebarPlan, applied bool) string {
+	if len(plan.Ops) == 0 {
+		return fmt.Sprintf("The sidebar of **%s** is in line with the structure.", username)
+	}
+
+	lines := []string{fmt.Sprintf("Changes to the sidebar of **%s** (preview, add `apply` to make them):", username)}
+	if applied {
+		lines[0] = fmt.Sprintf("Changed the sidebar of **%s**:", username)
+	}
+
+	for _, op := range plan.Ops {
+		switch op.Kind {
+		case business.OpCreateCategory:
+			lines = append(lines, fmt.Sprintf("- Create category %s", op.Category))
+		case business.OpMoveChannel:
+			channel := plan.ChannelNames[op.Channel]
+			if channel == "" {
+				channel = op.Channel
+			}
+			if op.From == "" || op.FromID == op.CategoryID {
+				lines = append(lines, fmt.Sprintf("- Put %s at position %d of %s", channel, op.Index+1, op.Category))
+			} else {
+				lines = append(lines, fmt.Sprintf("- Move %s from %s to position %d of %s", channel, op.From, op.Index+1, op.Category))
+			}
+		case business.OpSetSorting:
+			lines = append(lines, fmt.Sprintf("- Sort %s %s", op.Category, op.Sorting))
+		case business.OpDeleteCategory:
+			lines = append(lines, fmt.Sprintf("- Delete category %s", op.Category))
+		case business.OpSetSortOrder:
+			lines = append(lines, fmt.Sprintf("- Move category %s to position %d", op.Category, op.Index+1))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// renderRosterPlan lists the changes of the roster. Members not listed are
+// shown in bold, as removed if remove is set and as kept otherwise.
+func renderRosterPlan(plan *business.RosterPlan, applied bool, remove bool) string {
+	lines := []string{fmt.Sprintf("Membership changes of roster %s (preview, add `apply` to make them):", plan.File)}
+	if applied {
+		lines[0] = fmt.Sprintf("Changed the membership by roster %s:", plan.File)
+	}
+	if len(plan.Changes) == 0 {
+		lines = []string{fmt.Sprintf("The channels are in line with roster %s.", plan.File)}
+	}
+
+	kept := 0
+	for _, change := range plan.Changes {
+		if len(change.Add) > 0 {
+			lines = append(lines, fmt.Sprintf("- Add to %s: %s", change.Channel.DisplayName, renderUsernames(change.Add)))
+		}
+		if len(change.Remove) == 0 {
+			continue
+		}
+		if remove {
+			lines = append(lines, fmt.Sprintf("- **Remove from %s:** %s", change.Channel.DisplayName, renderUsernames(change.Remove)))
+		} else {
+			lines = append(lines, fmt.Sprintf("- **Not in the roster, kept in %s:** %s", change.Channel.DisplayName, renderUsernames(change.Remove)))
+			kept += len(change.Remove)
+		}
+	}
+	if kept > 0 {
+		lines = append(lines, fmt.Sprintf("**%d members are not in the roster, add `remove` to remove them from the channels.**", kept))
+	}
+	if len(plan.UnknownUsers) > 0 {
+		lines = append(lines, "Unknown users: "+strings.Join(plan.UnknownUsers, ", "))
+	}
+	if len(plan.OutsideTeam) > 0 {
+		lines = append(lines, "Not members of the team: "+strings.Join(plan.OutsideTeam, ", "))
+	}
+	if len(plan.UnknownChannels) > 0 {
+		lines = append(lines, "Unknown channels: "+strings.Join(plan.UnknownChannels, ", "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderUsernames(users []*model.User) string {
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	return strings.Join(names, ", ")
+}
+
+func renderOffboarding(username string, result *business.OffboardingResult) string {
+	lines := []string{fmt.Sprintf("Offboarded **%s**:", username)}
+	lines = append(lines, "Removed from channels: "+renderList(result.RemovedChannels))
+	lines = append(lines, "Deleted categories: "+renderList(result.DeletedCategories))
+	if result.Deactivated {
+		lines = append(lines, "The account was deactivated.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// auditShown is the number of latest audit entries /anchor audit shows.
+const auditShown = 20
+
+func renderAudit(entries []business.AuditEntry) string {
+	if len(entries) == 0 {
+		return "The audit trail is empty."
+	}
+	if len(entries) > auditShown {
+		entries = entries[len(entries)-auditShown:]
+	}
+
+	var lines []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		lines = append(lines, fmt.Sprintf("- %s **%s** %s %s: %s",
+			time.UnixMilli(entry.Time).UTC().Format(time.RFC3339), entry.Actor, entry.Action, entry.Subject, renderList(entry.Details)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderList(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
+
+func renderDiagnostics(diagnostics []business.Diagnostic) string {
+	if len(diagnostics) == 0 {
+		return "The structure definition is valid."
+	}
+
+	counts := make(map[business.Severity]int)
+	for _, diagnostic := range diagnostics {
+		counts[diagnostic.Severity]++
+	}
+
+	lines := []string{fmt.Sprintf("**Structure validation:** %d errors, %d warnings",
+		counts[business.SeverityError], counts[business.SeverityWarning])}
+	for _, diagnostic := range diagnostics {
+		lines = append(lines, fmt.Sprintf("- **%s** %s: %s. _Fix:_ %s",
+			diagnostic.Severity, diagnostic.Subject, diagnostic.Message, diagnostic.Fix))
+	}
+
+	return strings.Join(lines, "\n")
+}